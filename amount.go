@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+)
+
+const (
+	// defaultCurrencyScale is the number of decimal digits of amounts in
+	// most currencies, like EUR.
+	defaultCurrencyScale = 2
+)
+
+// formatAmount formats an amount in minor units as units and scale decimal
+// digits, separated by decimal.
+func formatAmount(v int64, decimal string, scale int) string {
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	if scale <= 0 {
+		return fmt.Sprintf("%s%d", sign, v)
+	}
+	unit := int64(1)
+	for i := 0; i < scale; i++ {
+		unit *= 10
+	}
+	return fmt.Sprintf("%s%d%s%0*d", sign, v/unit, decimal, scale, v%unit)
+}
+
+// valueDeltas returns the account change carried by each value, relatively to
+// the previous value of the same account. First values of each account have
+// a zero delta.
+func valueDeltas(values []Value) []int64 {
+	deltas := make([]int64, len(values))
+	last := map[string]int64{}
+	for i, v := range values {
+		if prev, ok := last[v.Account]; ok {
+			deltas[i] = v.Value - prev
+		}
+		last[v.Account] = v.Value
+	}
+	return deltas
+}
+
+// abs returns the absolute value of v.
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// valuesCurrency returns the currency of values, EUR if they have none, or an
+// error if they mix several currencies.
+func valuesCurrency(values []Value) (string, error) {
+	currency := ""
+	for _, v := range values {
+		if v.Currency == "" || v.Currency == currency {
+			continue
+		}
+		if currency != "" {
+			return "", fmt.Errorf("cannot mix %s and %s values", currency, v.Currency)
+		}
+		currency = v.Currency
+	}
+	if currency == "" {
+		currency = "EUR"
+	}
+	return currency, nil
+}
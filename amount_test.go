@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		value   int64
+		decimal string
+		scale   int
+		want    string
+	}{
+		{0, ".", 2, "0.00"},
+		{5, ".", 2, "0.05"},
+		{-5, ".", 2, "-0.05"},
+		{123456, ",", 2, "1234,56"},
+		{-100, ",", 2, "-1,00"},
+		{123456, ".", 3, "123.456"},
+		{-5, ".", 3, "-0.005"},
+		{1234, ".", 0, "1234"},
+	}
+	for _, test := range tests {
+		got := formatAmount(test.value, test.decimal, test.scale)
+		if got != test.want {
+			t.Errorf("formatAmount(%d, %q, %d) = %q != %q", test.value, test.decimal,
+				test.scale, got, test.want)
+		}
+	}
+}
+
+func TestValueDeltas(t *testing.T) {
+	values := []Value{
+		{Value: 1000, Account: "a"},
+		{Value: 500, Account: "b"},
+		{Value: 1200, Account: "a"},
+		{Value: 450, Account: "b"},
+		{Value: 1200, Account: "a"},
+	}
+	want := []int64{0, 0, 200, -50, 0}
+	got := valueDeltas(values)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected deltas: %v != %v", got, want)
+	}
+}
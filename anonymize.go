@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// tokenizer replaces strings with sequential tokens like "source-1", so
+// identical strings share the same token without revealing them.
+type tokenizer struct {
+	prefix string
+	tokens map[string]string
+}
+
+func newTokenizer(prefix string) *tokenizer {
+	return &tokenizer{
+		prefix: prefix,
+		tokens: map[string]string{},
+	}
+}
+
+// Token returns the token of s, empty strings are kept empty.
+func (t *tokenizer) Token(s string) string {
+	if s == "" {
+		return s
+	}
+	token, ok := t.tokens[s]
+	if !ok {
+		token = fmt.Sprintf("%s-%d", t.prefix, len(t.tokens)+1)
+		t.tokens[s] = token
+	}
+	return token
+}
+
+// anonymizeValues replaces values sources, accounts and categories with
+// tokens, so identical strings share the same token. Foreign amounts are
+// dropped. If jitter is positive, balances of each account are shifted by a
+// random amount of at most jitter minor units, which preserves account
+// changes.
+func anonymizeValues(values []Value, jitter int64, rnd *rand.Rand) []Value {
+	sources := newTokenizer("source")
+	accounts := newTokenizer("account")
+	categories := newTokenizer("category")
+	offsets := map[string]int64{}
+	anonymized := make([]Value, 0, len(values))
+	for _, v := range values {
+		account := v.Account
+		v.Source = sources.Token(v.Source)
+		v.Account = accounts.Token(v.Account)
+		v.Category = categories.Token(v.Category)
+		v.OriginalValue = 0
+		v.OriginalCurrency = ""
+		v.Words = nil
+		if jitter > 0 {
+			offset, ok := offsets[account]
+			if !ok {
+				offset = rnd.Int63n(2*jitter+1) - jitter
+				offsets[account] = offset
+			}
+			v.Value += offset
+		}
+		anonymized = append(anonymized, v)
+	}
+	return anonymized
+}
+
+var (
+	anonymizeCmd = app.Command("anonymize",
+		"replace JSON values sources, accounts and categories with tokens to share "+
+			"them in bug reports")
+	anonymizeValuesPath = anonymizeCmd.Arg("values", "JSON values to anonymize").
+				Required().String()
+	anonymizeOut = anonymizeCmd.Flag("out", "path to JSON output file, stdout by default").
+			String()
+	anonymizeJitter = anonymizeCmd.Flag("jitter",
+		"shift each account balances by a random amount up to this number of "+
+			"minor units").
+		Int64()
+)
+
+func anonymizeFn() error {
+	values, err := readJsonValues(*anonymizeValuesPath)
+	if err != nil {
+		return err
+	}
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	values = anonymizeValues(values, *anonymizeJitter, rnd)
+	return writeValues(values, *anonymizeOut, jsonWriter(true))
+}
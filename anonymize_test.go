@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnonymizeValues(t *testing.T) {
+	values := []Value{
+		{Date: testDay(1), Source: "SOLDE", Value: 100000, Account: "courant"},
+		{Date: testDay(5), Source: "PRLV EDF", Value: 97000, Amount: -3000,
+			Account: "courant", Category: "energie"},
+		{Date: testDay(6), Source: "SOLDE", Value: 50000, Account: "livret"},
+		{Date: testDay(9), Source: "CB AMAZON UK", Value: 95800, Amount: -1200,
+			Account: "courant", OriginalValue: -1000, OriginalCurrency: "GBP"},
+		{Date: testDay(12), Source: "PRLV EDF", Value: 92800, Amount: -3000,
+			Account: "courant", Category: "energie"},
+		{Date: testDay(15), Source: "INTERETS", Value: 50100, Amount: 100,
+			Account: "livret"},
+	}
+	for _, jitter := range []int64{0, 500} {
+		rnd := rand.New(rand.NewSource(1))
+		got := anonymizeValues(values, jitter, rnd)
+		if len(got) != len(values) {
+			t.Fatalf("jitter=%d: %d values != %d", jitter, len(got), len(values))
+		}
+		sources := map[string]string{}
+		for i, v := range got {
+			orig := values[i]
+			if prev, ok := sources[orig.Source]; ok && prev != v.Source {
+				t.Errorf("jitter=%d: %q got distinct tokens %q and %q", jitter,
+					orig.Source, prev, v.Source)
+			}
+			sources[orig.Source] = v.Source
+			for _, s := range []string{v.Source, v.Account, v.Category} {
+				if s != "" && !strings.Contains(s, "-") {
+					t.Errorf("jitter=%d: %q is not a token", jitter, s)
+				}
+			}
+			if v.Account == orig.Account || v.Category != "" && v.Category == orig.Category {
+				t.Errorf("jitter=%d: account or category not redacted: %+v", jitter, v)
+			}
+			if v.OriginalValue != 0 || v.OriginalCurrency != "" {
+				t.Errorf("jitter=%d: foreign amount not redacted: %+v", jitter, v)
+			}
+			if v.Amount != orig.Amount || !v.Date.Equal(orig.Date) {
+				t.Errorf("jitter=%d: amount or date changed: %+v", jitter, v)
+			}
+		}
+		if !reflect.DeepEqual(valueDeltas(got), valueDeltas(values)) {
+			t.Errorf("jitter=%d: deltas not preserved: %v != %v", jitter,
+				valueDeltas(got), valueDeltas(values))
+		}
+		if len(sources) != 4 || got[0].Source != got[2].Source {
+			t.Errorf("jitter=%d: unexpected sources tokens: %v", jitter, sources)
+		}
+	}
+}
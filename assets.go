@@ -0,0 +1,42 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+)
+
+//go:embed scripts
+var embeddedAssets embed.FS
+
+// openAssets returns the web assets file system, holding main.html and the
+// scripts it references. Assets are embedded in the binary unless dir is set,
+// in which case they are read from disk to ease development.
+func openAssets(dir string) (fs.FS, error) {
+	if dir != "" {
+		return os.DirFS(dir), nil
+	}
+	return fs.Sub(embeddedAssets, "scripts")
+}
+
+// readTemplate returns the HTML page values are embedded in, read from path
+// if set or main.html in assets otherwise. It must contain at least one $DATA$
+// or $DATA:name$ placeholder.
+func readTemplate(assets fs.FS, path string) ([]byte, error) {
+	var html []byte
+	var err error
+	if path != "" {
+		html, err = ioutil.ReadFile(path)
+	} else {
+		html, err = fs.ReadFile(assets, "main.html")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !reDataPlaceholder.Match(html) {
+		return nil, fmt.Errorf("template has no $DATA$ or $DATA:name$ placeholder")
+	}
+	return html, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadTemplate(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.html":        "<html>$DATA$</html>",
+		"named.html":       "<html>$DATA:monthly$</html>",
+		"placeholder.html": "<html>DATA</html>",
+	}
+	for name, content := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		dir  string
+		path string
+		want string
+		err  string
+	}{
+		{"", "", "var data = $DATA$;", ""},
+		{dir, "", "<html>$DATA$</html>", ""},
+		{"", filepath.Join(dir, "named.html"), "<html>$DATA:monthly$</html>", ""},
+		{"", filepath.Join(dir, "placeholder.html"), "", "no $DATA$"},
+		{"", filepath.Join(dir, "missing.html"), "", "no such file"},
+	}
+	for _, test := range tests {
+		assets, err := openAssets(test.dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		html, err := readTemplate(assets, test.path)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%q %q: expected %q error, got %v", test.dir, test.path,
+					test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q %q: %s", test.dir, test.path, err)
+			continue
+		}
+		if !strings.Contains(string(html), test.want) {
+			t.Errorf("%q %q: %q not found in template", test.dir, test.path, test.want)
+		}
+	}
+}
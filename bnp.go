@@ -18,6 +18,30 @@ func dispatch() error {
 		return parseFn()
 	case webCmd.FullCommand():
 		return webFn()
+	case reportCmd.FullCommand():
+		return reportFn()
+	case convertCmd.FullCommand():
+		return convertFn()
+	case csvCmd.FullCommand():
+		return csvFn()
+	case ofxCmd.FullCommand():
+		return ofxFn()
+	case qifCmd.FullCommand():
+		return qifFn()
+	case statsCmd.FullCommand():
+		return statsFn()
+	case mergeCmd.FullCommand():
+		return mergeFn()
+	case dumpCmd.FullCommand():
+		return dumpFn()
+	case diffCmd.FullCommand():
+		return diffFn()
+	case anonymizeCmd.FullCommand():
+		return anonymizeFn()
+	case pivotCmd.FullCommand():
+		return pivotFn()
+	case sourcesCmd.FullCommand():
+		return sourcesFn()
 	}
 	return nil
 }
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"unicode/utf16"
+
+	"github.com/pmezard/pdf"
+)
+
+const (
+	// maxCMapRange bounds the number of codes mapped by a single bfrange
+	// entry.
+	maxCMapRange = 1 << 16
+)
+
+// CMap maps the character codes of show-text strings to Unicode text, as
+// described by fonts ToUnicode streams.
+type CMap struct {
+	// Width is the number of bytes of character codes
+	Width int
+	Chars map[string]string
+}
+
+// parseCMap reads a ToUnicode CMap stream. Only bfchar and bfrange mappings
+// are supported, codes all have the width of the first code space range.
+func parseCMap(r io.Reader) (*CMap, error) {
+	m := &CMap{
+		Width: 1,
+		Chars: map[string]string{},
+	}
+	err := tokenize(r, func(keyword string, args []interface{}) error {
+		switch keyword {
+		case "endcodespacerange":
+			if len(args) > 0 {
+				if lo, ok := cmapString(args[0]); ok && len(lo) > 0 {
+					m.Width = len(lo)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(args); i += 2 {
+				code, ok1 := cmapString(args[i])
+				dst, ok2 := cmapString(args[i+1])
+				if !ok1 || !ok2 {
+					return fmt.Errorf("invalid bfchar entry: %v %v", args[i], args[i+1])
+				}
+				m.Chars[code] = utf16Text(dst)
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(args); i += 3 {
+				err := m.addRange(args[i], args[i+1], args[i+2])
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return m, err
+}
+
+// addRange maps codes from lo to hi to dst. dst is either an array of
+// destination strings, one per code, or the destination of lo which is
+// incremented for following codes.
+func (m *CMap) addRange(lo, hi, dst interface{}) error {
+	start, ok1 := cmapString(lo)
+	end, ok2 := cmapString(hi)
+	if !ok1 || !ok2 || len(start) != len(end) || len(start) > 4 {
+		return fmt.Errorf("invalid bfrange bounds: %v %v", lo, hi)
+	}
+	from, to := codeValue(start), codeValue(end)
+	if to < from || to-from >= maxCMapRange {
+		return fmt.Errorf("invalid bfrange bounds: %v %v", lo, hi)
+	}
+	dsts, isArray := dst.([]interface{})
+	base, ok := cmapString(dst)
+	if !isArray && !ok {
+		return fmt.Errorf("invalid bfrange destination: %v", dst)
+	}
+	units := utf16.Encode([]rune(utf16Text(base)))
+	for c := from; c <= to; c++ {
+		code := codeString(c, len(start))
+		if isArray {
+			i := int(c - from)
+			if i >= len(dsts) {
+				break
+			}
+			if s, ok := cmapString(dsts[i]); ok {
+				m.Chars[code] = utf16Text(s)
+			}
+			continue
+		}
+		if len(units) == 0 {
+			continue
+		}
+		// Only the last code unit is incremented
+		shifted := append([]uint16{}, units...)
+		shifted[len(shifted)-1] += uint16(c - from)
+		m.Chars[code] = string(utf16.Decode(shifted))
+	}
+	return nil
+}
+
+// Decode maps the character codes of s to text. Unmapped codes are kept.
+func (m *CMap) Decode(s string) string {
+	decoded := []byte{}
+	for i := 0; i < len(s); i += m.Width {
+		end := i + m.Width
+		if end > len(s) {
+			end = len(s)
+		}
+		if t, ok := m.Chars[s[i:end]]; ok {
+			decoded = append(decoded, t...)
+		} else {
+			decoded = append(decoded, s[i:end]...)
+		}
+	}
+	return string(decoded)
+}
+
+// fontCMap returns the ToUnicode CMap of font, or nil if it has none.
+func fontCMap(font pdf.Value) (*CMap, error) {
+	tu := font.Key("ToUnicode")
+	if tu.Kind() != pdf.Stream {
+		return nil, nil
+	}
+	r, err := openStream(tu)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	defer r.Close()
+	return parseCMap(r)
+}
+
+func codeValue(s string) uint32 {
+	v := uint32(0)
+	for i := 0; i < len(s); i++ {
+		v = v<<8 | uint32(s[i])
+	}
+	return v
+}
+
+func codeString(v uint32, width int) string {
+	b := make([]byte, width)
+	for i := width - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return string(b)
+}
+
+// utf16Text decodes UTF-16BE encoded s.
+func utf16Text(s string) string {
+	units := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// cmapString returns the bytes of a CMap string operand, as decoded by
+// pdf.Tokenize.
+func cmapString(v interface{}) (string, bool) {
+	s, ok := v.(string)
+	return s, ok
+}
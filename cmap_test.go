@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCMap(t *testing.T) {
+	src := `/CIDInit /ProcSet findresource begin
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+3 beginbfchar <0003> <0020> <0024> <0041> <005C> <0042> endbfchar
+2 beginbfrange <0044> <0046> <0061> <5C28> <5C29> [<00E9> <D83DDE00>] endbfrange
+endcmap`
+	m, err := parseCMap(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Width != 2 {
+		t.Fatalf("unexpected code width: %d", m.Width)
+	}
+	tests := []struct {
+		codes string
+		text  string
+	}{
+		{"\x00\x24\x00\x03", "A "},
+		// Codes containing backslashes or parentheses bytes
+		{"\x00\x5c\x5c\x28\x5c\x29", "Bé😀"},
+		{"\x00\x44\x00\x45\x00\x46", "abc"},
+		// Unmapped codes are kept
+		{"\x00\x99", "\x00\x99"},
+	}
+	for _, test := range tests {
+		got := m.Decode(test.codes)
+		if got != test.text {
+			t.Errorf("%q: %q != %q", test.codes, got, test.text)
+		}
+	}
+}
+
+func TestExtractToUnicode(t *testing.T) {
+	r := openTestPDF(t, "tounicode.pdf", "")
+	streams, err := extractPageLines(NewParserConfig(), r.Page(1), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) == 0 {
+		t.Fatal("no content stream found")
+	}
+	// F2 has no ToUnicode CMap and its codes must not go through F1 one
+	want := []string{"SOLDE", "AU", "\x003"}
+	got := lineValues(streams[0])
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected lines: %q != %q", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// valueWriters maps output format names to functions returning their
+	// ValueWriter for amounts with a given number of decimal digits.
+	valueWriters = map[string]func(scale int) ValueWriter{
+		"json":    func(int) ValueWriter { return jsonWriter(false) },
+		"ndjson":  func(int) ValueWriter { return writeNdjson },
+		"csv":     func(scale int) ValueWriter { return csvWriter(".", scale) },
+		"ofx":     ofxWriter,
+		"qif":     func(scale int) ValueWriter { return qifWriter("02/01/2006", scale) },
+		"grafana": func(int) ValueWriter { return writeGrafana },
+	}
+)
+
+// valueWriterNames returns the sorted list of registered output formats.
+func valueWriterNames() []string {
+	names := []string{}
+	for name := range valueWriters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	convertCmd    = app.Command("convert", "convert JSON values to another format")
+	convertValues = convertCmd.Arg("values", "JSON values to convert").Required().String()
+	convertFormat = convertCmd.Flag("format", "output format").
+			Default("json").String()
+	convertOut = convertCmd.Flag("out", "path to output file, stdout by default").
+			String()
+	convertCurrencyScale = convertCmd.Flag("currency-scale",
+		"number of decimal digits of amounts").Default("2").Int()
+)
+
+func convertFn() error {
+	newWriter, ok := valueWriters[*convertFormat]
+	if !ok {
+		return fmt.Errorf("unknown format %q, must be one of: %s", *convertFormat,
+			strings.Join(valueWriterNames(), ", "))
+	}
+	values, err := readJsonValues(*convertValues)
+	if err != nil {
+		return err
+	}
+	return writeValues(values, *convertOut, newWriter(*convertCurrencyScale))
+}
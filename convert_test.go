@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestValueWriters(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"csv", "2020-03-02,\"CB \"\"SHOP\"\", A\",98.766,-1.234,\n"},
+		{"grafana", `{"target":"salary","datapoints":[[200000,1584230400000]]}`},
+		{"json", `"Source":"VIR SALAIRE","Value":298766`},
+		{"ndjson", "\n{\"Date\":\"2020-03-15T00:00:00Z\","},
+		{"ofx", "<TRNAMT>200.000"},
+		{"qif", "T200.000\n"},
+	}
+	names := []string{}
+	for _, test := range tests {
+		names = append(names, test.format)
+		newWriter, ok := valueWriters[test.format]
+		if !ok {
+			t.Errorf("%s: unknown format", test.format)
+			continue
+		}
+		buf := &bytes.Buffer{}
+		err := newWriter(3)(buf, testValues())
+		if err != nil {
+			t.Errorf("%s: %s", test.format, err)
+			continue
+		}
+		if !strings.Contains(buf.String(), test.want) {
+			t.Errorf("%s: %q not found in:\n%s", test.format, test.want, buf.String())
+		}
+	}
+	if got := strings.Join(valueWriterNames(), ","); got != strings.Join(names, ",") {
+		t.Errorf("unexpected formats: %s != %s", got, strings.Join(names, ","))
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// csvWriter returns a ValueWriter encoding values as CSV rows of date,
+// source, balance, delta and currency. Amounts are in currency units, with
+// decimal separating their scale decimal digits.
+func csvWriter(decimal string, scale int) ValueWriter {
+	return func(w io.Writer, values []Value) error {
+		cw := csv.NewWriter(w)
+		err := cw.Write([]string{"date", "source", "balance", "delta", "currency"})
+		if err != nil {
+			return err
+		}
+		deltas := valueDeltas(values)
+		for i, v := range values {
+			err := cw.Write([]string{
+				v.Date.Format("2006-01-02"),
+				v.Source,
+				formatAmount(v.Value, decimal, scale),
+				formatAmount(deltas[i], decimal, scale),
+				v.Currency,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+}
+
+var (
+	csvCmd     = app.Command("csv", "convert JSON values to CSV")
+	csvValues  = csvCmd.Arg("values", "JSON values to convert").Required().String()
+	csvOutput  = csvCmd.Flag("output", "path to CSV output file, stdout by default").String()
+	csvDecimal = csvCmd.Flag("decimal", "decimal separator of amounts").
+			Default(".").String()
+	csvCurrencyScale = csvCmd.Flag("currency-scale",
+		"number of decimal digits of amounts").Default("2").Int()
+)
+
+func csvFn() error {
+	values, err := readJsonValues(*csvValues)
+	if err != nil {
+		return err
+	}
+	return writeValues(values, *csvOutput, csvWriter(*csvDecimal, *csvCurrencyScale))
+}
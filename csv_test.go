@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCsvWriter(t *testing.T) {
+	tests := []struct {
+		decimal string
+		scale   int
+		want    string
+	}{
+		{".", 2, `date,source,balance,delta,currency
+2020-03-01,SOLDE,1000.00,0.00,
+2020-03-02,"CB ""SHOP"", A",987.66,-12.34,
+2020-03-15,VIR SALAIRE,2987.66,2000.00,
+2020-03-20,CB SHOP,2982.66,-5.00,
+`},
+		{",", 3, `date,source,balance,delta,currency
+2020-03-01,SOLDE,"100,000","0,000",
+2020-03-02,"CB ""SHOP"", A","98,766","-1,234",
+2020-03-15,VIR SALAIRE,"298,766","200,000",
+2020-03-20,CB SHOP,"298,266","-0,500",
+`},
+	}
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		err := csvWriter(test.decimal, test.scale)(buf, testValues())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%q %d: unexpected output:\n%s\n!=\n%s", test.decimal,
+				test.scale, got, test.want)
+		}
+	}
+}
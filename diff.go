@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// valueKey identifies the operation carried by a value. The account change
+// is used instead of the balance, so a missing operation does not make all
+// following ones differ.
+type valueKey struct {
+	Date    string
+	Account string
+	Source  string
+	Delta   int64
+}
+
+// Format returns k as a line, with amounts of scale decimal digits.
+func (k valueKey) Format(scale int) string {
+	return fmt.Sprintf("%s %s %q %s", k.Date, k.Account, k.Source,
+		formatAmount(k.Delta, ".", scale))
+}
+
+// countValueKeys returns the number of occurrences of each value key.
+func countValueKeys(values []Value) map[valueKey]int {
+	counts := map[valueKey]int{}
+	for i, delta := range valueDeltas(values) {
+		v := values[i]
+		counts[valueKey{v.Date.Format("2006-01-02"), v.Account, v.Source, delta}]++
+	}
+	return counts
+}
+
+// dayBalances returns the end of day balance of each account, keyed by
+// account then day.
+func dayBalances(values []Value) map[string]map[string]int64 {
+	balances := map[string]map[string]int64{}
+	for _, v := range values {
+		if balances[v.Account] == nil {
+			balances[v.Account] = map[string]int64{}
+		}
+		balances[v.Account][v.Date.Format("2006-01-02")] = v.Value
+	}
+	return balances
+}
+
+// diffValues returns the differences between before and after values, as
+// lines prefixed with "-" for removed operations, "+" for added ones and "~"
+// for balances differing at the end of a day listed by both. Amounts have
+// scale decimal digits.
+func diffValues(before, after []Value, scale int) []string {
+	diffs := []string{}
+	oldKeys, newKeys := countValueKeys(before), countValueKeys(after)
+	keys := []valueKey{}
+	for k := range oldKeys {
+		keys = append(keys, k)
+	}
+	for k := range newKeys {
+		if _, ok := oldKeys[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Format(scale) < keys[j].Format(scale)
+	})
+	for _, k := range keys {
+		for n := newKeys[k]; n < oldKeys[k]; n++ {
+			diffs = append(diffs, "- "+k.Format(scale))
+		}
+		for n := oldKeys[k]; n < newKeys[k]; n++ {
+			diffs = append(diffs, "+ "+k.Format(scale))
+		}
+	}
+	oldBalances, newBalances := dayBalances(before), dayBalances(after)
+	changed := []string{}
+	for account, days := range oldBalances {
+		for day, balance := range days {
+			b, ok := newBalances[account][day]
+			if ok && b != balance {
+				changed = append(changed, fmt.Sprintf("~ %s %s balance: %s != %s",
+					day, account, formatAmount(balance, ".", scale),
+					formatAmount(b, ".", scale)))
+			}
+		}
+	}
+	sort.Strings(changed)
+	return append(diffs, changed...)
+}
+
+var (
+	diffCmd = app.Command("diff", `compare two JSON values files
+
+diff lists operations removed from the first file with "-", operations added
+in the second one with "+" and end of day balances differing with "~". It
+fails if the files differ.
+`)
+	diffOld           = diffCmd.Arg("old", "reference JSON values").Required().String()
+	diffNew           = diffCmd.Arg("new", "JSON values to compare").Required().String()
+	diffCurrencyScale = diffCmd.Flag("currency-scale",
+		"number of decimal digits of amounts").Default("2").Int()
+)
+
+func diffFn() error {
+	before, err := readJsonValues(*diffOld)
+	if err != nil {
+		return err
+	}
+	after, err := readJsonValues(*diffNew)
+	if err != nil {
+		return err
+	}
+	diffs := diffValues(before, after, *diffCurrencyScale)
+	for _, d := range diffs {
+		fmt.Println(d)
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("%d differences found", len(diffs))
+	}
+	return nil
+}
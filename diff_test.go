@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffValues(t *testing.T) {
+	before := testValues()
+	tests := []struct {
+		name  string
+		after func(values []Value) []Value
+		scale int
+		want  []string
+	}{
+		{"identical", func(values []Value) []Value { return values }, 2, []string{}},
+		{"changed", func(values []Value) []Value {
+			// The last debit becomes a zero amount operation
+			return append(values[:3:3], Value{Date: testDay(20), Source: "CB SHOP",
+				Value: 298766, Amount: 0, Category: "shopping"})
+		}, 2, []string{
+			`- 2020-03-20  "CB SHOP" -5.00`,
+			`+ 2020-03-20  "CB SHOP" 0.00`,
+			`~ 2020-03-20  balance: 2982.66 != 2987.66`,
+		}},
+		{"added", func(values []Value) []Value {
+			return append(values, Value{Date: testDay(21), Source: "CB NEW",
+				Value: 298166})
+		}, 3, []string{
+			`+ 2020-03-21  "CB NEW" -0.100`,
+		}},
+	}
+	for _, test := range tests {
+		after := test.after(testValues())
+		got := diffValues(before, after, test.scale)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: unexpected diff:\n%s\n!=\n%s", test.name,
+				strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+)
+
+var (
+	dumpCmd = app.Command("dump", `print text lines extracted from a PDF report
+
+dump prints the lines of each page as seen by the parser, prefixed with the
+column of their first word. It helps understanding why operations were not
+recognized.
+`)
+	dumpFile     = dumpCmd.Arg("file", "PDF file to dump, - reads stdin").Required().String()
+	dumpPassword = dumpCmd.Flag("password", "password of encrypted PDF file").String()
+)
+
+func dumpFn() error {
+	r, err := openPDF(*dumpFile, *dumpPassword)
+	if err != nil {
+		return &OpenError{File: *dumpFile, Err: err}
+	}
+	cfg := NewParserConfig()
+	for i := 1; i <= r.NumPage(); i++ {
+		streams, err := extractPageLines(cfg, r.Page(i), i)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("page %d:\n", i)
+		for _, lines := range streams {
+			for _, line := range lines {
+				col := 0.
+				if len(line.Words) > 0 {
+					col = line.Words[0].Column
+				}
+				fmt.Printf("%8.2f %s\n", col, line.Value)
+			}
+		}
+	}
+	return nil
+}
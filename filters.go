@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// lzwReader decodes PDF LZWDecode streams. Unlike compress/lzw, code widths
+// can grow one code early, which PDF does unless told otherwise with the
+// EarlyChange parameter. Input is decoded entirely on first Read.
+type lzwReader struct {
+	r           io.Reader
+	earlyChange bool
+	out         *bytes.Reader
+}
+
+func newLZWReader(r io.Reader, earlyChange bool) io.ReadCloser {
+	return &lzwReader{
+		r:           r,
+		earlyChange: earlyChange,
+	}
+}
+
+func (l *lzwReader) Read(data []byte) (int, error) {
+	if l.out == nil {
+		decoded, err := decodeLZW(l.r, l.earlyChange)
+		if err != nil {
+			return 0, err
+		}
+		l.out = bytes.NewReader(decoded)
+	}
+	return l.out.Read(data)
+}
+
+func (l *lzwReader) Close() error {
+	return nil
+}
+
+const (
+	lzwClear = 256
+	lzwEOD   = 257
+	lzwFirst = 258
+	lzwMax   = 4096
+)
+
+// decodeLZW decodes an LZW stream made of 9 to 12 bits codes, most significant
+// bits first.
+func decodeLZW(r io.Reader, earlyChange bool) ([]byte, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	early := 0
+	if earlyChange {
+		early = 1
+	}
+	table := make([][]byte, lzwFirst, lzwMax)
+	for i := 0; i < 256; i++ {
+		table[i] = []byte{byte(i)}
+	}
+	width := uint(9)
+	out := []byte{}
+	var prev []byte
+	bits, nbits := uint32(0), uint(0)
+	for pos := 0; ; {
+		for nbits < width {
+			if pos >= len(data) {
+				// Missing EOD marker
+				return out, nil
+			}
+			bits = bits<<8 | uint32(data[pos])
+			pos++
+			nbits += 8
+		}
+		nbits -= width
+		code := int(bits >> nbits & (1<<width - 1))
+		bits &= 1<<nbits - 1
+
+		switch code {
+		case lzwClear:
+			table = table[:lzwFirst]
+			width = 9
+			prev = nil
+			continue
+		case lzwEOD:
+			return out, nil
+		}
+		var entry []byte
+		if code < len(table) {
+			entry = table[code]
+		} else if code == len(table) && prev != nil {
+			entry = append(prev[:len(prev):len(prev)], prev[0])
+		} else {
+			return nil, fmt.Errorf("invalid LZW code: %d", code)
+		}
+		out = append(out, entry...)
+		if prev != nil && len(table) < lzwMax {
+			added := make([]byte, len(prev)+1)
+			copy(added, prev)
+			added[len(prev)] = entry[0]
+			table = append(table, added)
+		}
+		prev = entry
+		if len(table)+early >= 1<<width && width < 12 {
+			width++
+		}
+	}
+}
+
+// pngReader reverses PNG predictors applied row by row on decoded stream
+// data, as selected by DecodeParms Predictor values 10 and above.
+type pngReader struct {
+	r    io.Reader
+	bpp  int
+	prev []byte
+	cur  []byte
+	out  []byte
+}
+
+func newPNGReader(r io.Reader, colors, bitsPerComponent, columns int) io.ReadCloser {
+	rowSize := (colors*bitsPerComponent*columns + 7) / 8
+	bpp := (colors*bitsPerComponent + 7) / 8
+	return &pngReader{
+		r:    r,
+		bpp:  bpp,
+		prev: make([]byte, rowSize),
+		cur:  make([]byte, rowSize+1),
+	}
+}
+
+func (p *pngReader) Read(data []byte) (int, error) {
+	if len(p.out) == 0 {
+		_, err := io.ReadFull(p.r, p.cur)
+		if err == io.ErrUnexpectedEOF {
+			err = fmt.Errorf("truncated PNG predictor row")
+		}
+		if err != nil {
+			return 0, err
+		}
+		row := p.cur[1:]
+		for i := range row {
+			left, upLeft := 0, 0
+			if i >= p.bpp {
+				left = int(row[i-p.bpp])
+				upLeft = int(p.prev[i-p.bpp])
+			}
+			up := int(p.prev[i])
+			switch p.cur[0] {
+			case 0: // None
+			case 1: // Sub
+				row[i] += byte(left)
+			case 2: // Up
+				row[i] += byte(up)
+			case 3: // Average
+				row[i] += byte((left + up) / 2)
+			case 4: // Paeth
+				row[i] += byte(paeth(left, up, upLeft))
+			default:
+				return 0, fmt.Errorf("unknown PNG predictor: %d", p.cur[0])
+			}
+		}
+		copy(p.prev, row)
+		p.out = p.prev
+	}
+	n := copy(data, p.out)
+	p.out = p.out[n:]
+	return n, nil
+}
+
+func (p *pngReader) Close() error {
+	return nil
+}
+
+func paeth(a, b, c int) int {
+	pa := abs(int64(b - c))
+	pb := abs(int64(a - c))
+	pc := abs(int64(a + b - 2*c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+// runLengthReader decodes RunLengthDecode streams. Each run starts with a
+// length byte: 0 to 127 copy the next length+1 bytes, 129 to 255 repeat the
+// next byte 257-length times and 128 ends the data.
+type runLengthReader struct {
+	r    *bufio.Reader
+	out  []byte
+	done bool
+}
+
+func newRunLengthReader(r io.Reader) io.ReadCloser {
+	return &runLengthReader{
+		r: bufio.NewReader(r),
+	}
+}
+
+func (rl *runLengthReader) Read(data []byte) (int, error) {
+	for len(rl.out) == 0 {
+		if rl.done {
+			return 0, io.EOF
+		}
+		n, err := rl.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case n < 128:
+			run := make([]byte, int(n)+1)
+			_, err = io.ReadFull(rl.r, run)
+			rl.out = run
+		case n > 128:
+			var c byte
+			c, err = rl.r.ReadByte()
+			rl.out = bytes.Repeat([]byte{c}, 257-int(n))
+		default:
+			rl.done = true
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return 0, fmt.Errorf("truncated run-length data")
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(data, rl.out)
+	rl.out = rl.out[n:]
+	return n, nil
+}
+
+func (rl *runLengthReader) Close() error {
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	tests := []struct {
+		name   string
+		reader func(r io.Reader) io.ReadCloser
+		input  []byte
+		want   []byte
+		err    string
+	}{
+		// Example of the PDF specification, section 7.4.4.2
+		{"lzw", func(r io.Reader) io.ReadCloser { return newLZWReader(r, true) },
+			[]byte{0x80, 0x0b, 0x60, 0x50, 0x22, 0x0c, 0x0c, 0x85, 0x01},
+			[]byte("-----A---B"), ""},
+		{"lzw invalid code",
+			func(r io.Reader) io.ReadCloser { return newLZWReader(r, true) },
+			[]byte{0xff, 0xff}, nil, "invalid LZW code"},
+		{"png", func(r io.Reader) io.ReadCloser { return newPNGReader(r, 1, 8, 3) },
+			[]byte{
+				2, 1, 2, 3, // Up
+				1, 1, 1, 1, // Sub
+				2, 1, 1, 1, // Up
+				4, 0, 0, 0, // Paeth
+				0, 7, 8, 9, // None
+			},
+			[]byte{1, 2, 3, 1, 2, 3, 2, 3, 4, 2, 3, 4, 7, 8, 9}, ""},
+		{"png truncated",
+			func(r io.Reader) io.ReadCloser { return newPNGReader(r, 1, 8, 3) },
+			[]byte{0, 1, 2}, nil, "truncated PNG predictor row"},
+		{"png unknown predictor",
+			func(r io.Reader) io.ReadCloser { return newPNGReader(r, 1, 8, 3) },
+			[]byte{5, 1, 2, 3}, nil, "unknown PNG predictor: 5"},
+		{"run-length", newRunLengthReader,
+			[]byte{2, 'a', 'b', 'c', 254, 'x', 128, 'z'}, []byte("abcxxx"), ""},
+		{"run-length truncated", newRunLengthReader,
+			[]byte{2, 'a'}, nil, "truncated run-length data"},
+	}
+	for _, test := range tests {
+		r := test.reader(bytes.NewReader(test.input))
+		got, err := ioutil.ReadAll(r)
+		r.Close()
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected %q error, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if !bytes.Equal(got, test.want) {
+			t.Errorf("%s: unexpected output: %q != %q", test.name, got, test.want)
+		}
+	}
+}
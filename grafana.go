@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// GrafanaSeries is a time series in the Grafana JSON datasource format. Each
+// datapoint is a [value, unix milliseconds] pair.
+type GrafanaSeries struct {
+	Target     string     `json:"target"`
+	Datapoints [][2]int64 `json:"datapoints"`
+}
+
+// writeGrafana encodes values as Grafana time series, sorted by time. The
+// "balance" series carries the accounts balances, and each category gets a
+// series of the account changes tagged with it. Amounts are in minor units.
+func writeGrafana(w io.Writer, values []Value) error {
+	values = append([]Value{}, values...)
+	sortValues(values)
+	balance := &GrafanaSeries{Target: "balance", Datapoints: [][2]int64{}}
+	categories := map[string]*GrafanaSeries{}
+	for i, delta := range valueDeltas(values) {
+		v := values[i]
+		ms := v.Date.UnixNano() / 1e6
+		balance.Datapoints = append(balance.Datapoints, [2]int64{v.Value, ms})
+		if v.Category == "" {
+			continue
+		}
+		s := categories[v.Category]
+		if s == nil {
+			s = &GrafanaSeries{Target: v.Category}
+			categories[v.Category] = s
+		}
+		s.Datapoints = append(s.Datapoints, [2]int64{delta, ms})
+	}
+	series := []*GrafanaSeries{balance}
+	names := []string{}
+	for name := range categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		series = append(series, categories[name])
+	}
+	return json.NewEncoder(w).Encode(series)
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteGrafana(t *testing.T) {
+	values := testValues()
+	// Input order does not matter
+	values[0], values[3] = values[3], values[0]
+	buf := &bytes.Buffer{}
+	err := writeGrafana(buf, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"target":"balance","datapoints":[[100000,1583020800000],` +
+		`[98766,1583107200000],[298766,1584230400000],[298266,1584662400000]]},` +
+		`{"target":"salary","datapoints":[[200000,1584230400000]]},` +
+		`{"target":"shopping","datapoints":[[-500,1584662400000]]}]` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected output:\n%s\n!=\n%s", got, want)
+	}
+}
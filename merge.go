@@ -0,0 +1,27 @@
+package main
+
+var (
+	mergeCmd = app.Command("merge", `merge JSON values files
+
+merge reads several JSON values files, sorts their values by date and writes
+them as a single sequence. Identical values appearing in several files are
+kept once.
+`)
+	mergeFiles = mergeCmd.Arg("files", "JSON values files to merge").Required().
+			Strings()
+	mergeOut = mergeCmd.Flag("out", "path to JSON output file, stdout by default").
+			String()
+	mergePretty = mergeCmd.Flag("pretty", "indent JSON output").Bool()
+)
+
+func mergeFn() error {
+	seqs := [][]Value{}
+	for _, path := range *mergeFiles {
+		values, err := readJsonValues(path)
+		if err != nil {
+			return err
+		}
+		seqs = append(seqs, values)
+	}
+	return writeValues(mergeValues(seqs...), *mergeOut, jsonWriter(*mergePretty))
+}
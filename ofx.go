@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	ofxHeader = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+SECURITY:NONE
+ENCODING:USASCII
+CHARSET:1252
+COMPRESSION:NONE
+OLDFILEUID:NONE
+NEWFILEUID:NONE
+
+`
+	ofxDate = "20060102"
+)
+
+var (
+	ofxEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+// ofxText escapes s for OFX SGML content and truncates it to max runes if max
+// is positive.
+func ofxText(s string, max int) string {
+	if r := []rune(s); max > 0 && len(r) > max {
+		s = string(r[:max])
+	}
+	return ofxEscaper.Replace(s)
+}
+
+// ofxID returns a stable transaction identifier derived from the value and
+// its delta, like hashOp does for operations.
+func ofxID(v Value, delta int64) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s-%s-%d-%d-%s", v.Date.Format(ofxDate), v.Source, v.Value,
+		delta, v.Account)
+	return fmt.Sprintf("%x", h.Sum(nil))[:20]
+}
+
+// ofxWriter returns a ValueWriter encoding values as an OFX 1.x bank
+// statement per account, with amounts of scale decimal digits. Each account
+// change becomes a STMTTRN, account records carrying no change are omitted.
+// Values of an account must share the same currency.
+func ofxWriter(scale int) ValueWriter {
+	return func(w io.Writer, values []Value) error {
+		buf := &strings.Builder{}
+		buf.WriteString(ofxHeader)
+		buf.WriteString("<OFX>\n")
+		buf.WriteString("<SIGNONMSGSRSV1><SONRS>\n")
+		buf.WriteString("<STATUS><CODE>0<SEVERITY>INFO</STATUS>\n")
+		if len(values) > 0 {
+			fmt.Fprintf(buf, "<DTSERVER>%s\n", values[len(values)-1].Date.Format(ofxDate))
+		}
+		buf.WriteString("<LANGUAGE>FRA\n")
+		buf.WriteString("</SONRS></SIGNONMSGSRSV1>\n")
+		buf.WriteString("<BANKMSGSRSV1>\n")
+		accounts, byAccount := splitAccounts(values)
+		for i, account := range accounts {
+			vals := byAccount[account]
+			if account == "" {
+				account = "UNKNOWN"
+			}
+			currency, err := valuesCurrency(vals)
+			if err != nil {
+				return fmt.Errorf("account %s: %w", account, err)
+			}
+			first, last := vals[0], vals[len(vals)-1]
+			fmt.Fprintf(buf, "<STMTTRNRS><TRNUID>%d\n", i+1)
+			buf.WriteString("<STATUS><CODE>0<SEVERITY>INFO</STATUS>\n")
+			fmt.Fprintf(buf, "<STMTRS><CURDEF>%s\n", currency)
+			fmt.Fprintf(buf, "<BANKACCTFROM><BANKID>BNP<ACCTID>%s<ACCTTYPE>CHECKING</BANKACCTFROM>\n",
+				ofxText(account, 22))
+			fmt.Fprintf(buf, "<BANKTRANLIST><DTSTART>%s<DTEND>%s\n",
+				first.Date.Format(ofxDate), last.Date.Format(ofxDate))
+			for j, delta := range valueDeltas(vals) {
+				if delta == 0 {
+					continue
+				}
+				v := vals[j]
+				kind := "CREDIT"
+				if delta < 0 {
+					kind = "DEBIT"
+				}
+				buf.WriteString("<STMTTRN>")
+				fmt.Fprintf(buf, "<TRNTYPE>%s", kind)
+				fmt.Fprintf(buf, "<DTPOSTED>%s", v.Date.Format(ofxDate))
+				fmt.Fprintf(buf, "<TRNAMT>%s", formatAmount(delta, ".", scale))
+				fmt.Fprintf(buf, "<FITID>%s", ofxID(v, delta))
+				fmt.Fprintf(buf, "<NAME>%s", ofxText(v.Source, 32))
+				fmt.Fprintf(buf, "<MEMO>%s", ofxText(v.Source, 0))
+				buf.WriteString("</STMTTRN>\n")
+			}
+			buf.WriteString("</BANKTRANLIST>\n")
+			fmt.Fprintf(buf, "<LEDGERBAL><BALAMT>%s<DTASOF>%s</LEDGERBAL>\n",
+				formatAmount(last.Value, ".", scale), last.Date.Format(ofxDate))
+			buf.WriteString("</STMTRS></STMTTRNRS>\n")
+		}
+		buf.WriteString("</BANKMSGSRSV1>\n")
+		buf.WriteString("</OFX>\n")
+		_, err := io.WriteString(w, buf.String())
+		return err
+	}
+}
+
+var (
+	ofxCmd           = app.Command("ofx", "convert JSON values to an OFX bank statement")
+	ofxValues        = ofxCmd.Arg("values", "JSON values to convert").Required().String()
+	ofxOutput        = ofxCmd.Flag("output", "path to OFX output file, stdout by default").String()
+	ofxCurrencyScale = ofxCmd.Flag("currency-scale",
+		"number of decimal digits of amounts").Default("2").Int()
+)
+
+func ofxFn() error {
+	values, err := readJsonValues(*ofxValues)
+	if err != nil {
+		return err
+	}
+	return writeValues(values, *ofxOutput, ofxWriter(*ofxCurrencyScale))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestOfxWriterTransactions(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := ofxWriter(2)(buf, testValues())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	// The opening account record carries no change and is omitted
+	for _, want := range []string{
+		"<BANKTRANLIST><DTSTART>20200301<DTEND>20200320\n",
+		"<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20200302<TRNAMT>-12.34" +
+			"<FITID>2992a9d192f3610f5ea6<NAME>CB \"SHOP\", A<MEMO>CB \"SHOP\", A</STMTTRN>\n" +
+			"<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20200315<TRNAMT>2000.00" +
+			"<FITID>b72381388bd9b940d907<NAME>VIR SALAIRE<MEMO>VIR SALAIRE</STMTTRN>\n" +
+			"<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20200320<TRNAMT>-5.00" +
+			"<FITID>108567da3946fe4f2226<NAME>CB SHOP<MEMO>CB SHOP</STMTTRN>\n" +
+			"</BANKTRANLIST>\n",
+		"<LEDGERBAL><BALAMT>2982.66<DTASOF>20200320</LEDGERBAL>\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%q not found in:\n%s", want, out)
+		}
+	}
+}
+
+func TestOfxWriterCurrency(t *testing.T) {
+	tests := []struct {
+		name       string
+		currencies []string
+		want       string
+		err        string
+	}{
+		{"default", []string{"", ""}, "<CURDEF>EUR\n", ""},
+		{"single", []string{"USD", "USD"}, "<CURDEF>USD\n", ""},
+		{"partial", []string{"", "CHF"}, "<CURDEF>CHF\n", ""},
+		{"mixed", []string{"EUR", "USD"}, "", "cannot mix EUR and USD"},
+	}
+	for _, test := range tests {
+		values := []Value{}
+		for i, currency := range test.currencies {
+			values = append(values, Value{Date: testDay(i + 1), Source: "CB",
+				Value: int64(1000 * (i + 1)), Currency: currency})
+		}
+		buf := &bytes.Buffer{}
+		err := ofxWriter(3)(buf, values)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected %q error, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		out := buf.String()
+		if !strings.Contains(out, test.want) {
+			t.Errorf("%s: %q not found in:\n%s", test.name, test.want, out)
+		}
+		if !strings.Contains(out, "<TRNAMT>1.000") {
+			t.Errorf("%s: 3 decimal digits amount not found in:\n%s", test.name, out)
+		}
+	}
+}
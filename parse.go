@@ -1,23 +1,114 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
-	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha1"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
+	"log"
+	"math"
 	"os"
+	"path"
+	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"regexp"
 
+	"github.com/pmezard/bnp/pdfwalk"
 	"github.com/pmezard/pdf"
 )
 
+// Errors categories, returned wrapped with more context.
+var (
+	ErrNotEnoughOps      = errors.New("not enough operations")
+	ErrReconcileMismatch = errors.New("running total does not match account record")
+	ErrUnknownFilter     = errors.New("unknown stream filter")
+	ErrEncrypted         = errors.New("encrypted PDF")
+)
+
+// OpenError is returned when a report file cannot be opened.
+type OpenError struct {
+	File string
+	Err  error
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("could not open %s: %s", e.File, e.Err)
+}
+
+func (e *OpenError) Unwrap() error {
+	return e.Err
+}
+
+// FilterError is returned when a stream is encoded with an unsupported
+// filter. It wraps ErrUnknownFilter.
+type FilterError struct {
+	Name string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrUnknownFilter, e.Name)
+}
+
+func (e *FilterError) Unwrap() error {
+	return ErrUnknownFilter
+}
+
+// ReconcileError is returned when an account record does not match the
+// running total of previous operations, in minor units. It wraps
+// ErrReconcileMismatch.
+type ReconcileError struct {
+	Op       *Op
+	Expected int64 // account record value
+	Actual   int64 // running total
+}
+
+func (e *ReconcileError) Error() string {
+	return fmt.Sprintf("%s %+v: %d != %d", ErrReconcileMismatch, e.Op,
+		e.Expected, e.Actual)
+}
+
+func (e *ReconcileError) Unwrap() error {
+	return ErrReconcileMismatch
+}
+
+// NotEnoughOpsError is returned when a report has less than an opening and a
+// closing account record. It wraps ErrNotEnoughOps.
+type NotEnoughOpsError struct {
+	Count int
+}
+
+func (e *NotEnoughOpsError) Error() string {
+	return fmt.Sprintf("%s in report: %d", ErrNotEnoughOps, e.Count)
+}
+
+func (e *NotEnoughOpsError) Unwrap() error {
+	return ErrNotEnoughOps
+}
+
+// FailedReportsError is returned when some reports could not be opened or
+// parsed, after reporting them on stderr.
+type FailedReportsError struct {
+	Count int
+}
+
+func (e *FailedReportsError) Error() string {
+	return fmt.Sprintf("%d reports failed", e.Count)
+}
+
 // MultiCloser references a sequence of io.ReadCloser, delegates writes to the
 // last one and close all of them in order in Close(). Use it when stacking
 // filters one onto another.
@@ -42,14 +133,31 @@ func (r *MultiCloser) Close() error {
 
 // extractStream takes a raw PDF object stream and the list of its filters and
 // returns an io.Reader applying all filters on it.
-func extractStream(r io.ReadCloser, filters []string) (io.ReadCloser, error) {
+func extractStream(r io.ReadCloser, filters []pdfwalk.Filter) (io.ReadCloser, error) {
 	readers := []io.ReadCloser{r}
 	for _, f := range filters {
-		if f == "FlateDecode" {
-			r = flate.NewReader(r)
+		switch f.Name {
+		case "FlateDecode":
+			// FlateDecode data is zlib encoded, not raw deflate
+			zr, err := zlib.NewReader(r)
+			if err != nil {
+				return nil, fmt.Errorf("could not decode %s stream: %w", f.Name, err)
+			}
+			r = zr
+		case "LZWDecode":
+			r = newLZWReader(r, f.EarlyChange != 0)
+		case "RunLengthDecode":
+			r = newRunLengthReader(r)
+		default:
+			return nil, &FilterError{Name: f.Name}
+		}
+		readers = append(readers, r)
+		switch {
+		case f.Predictor >= 10:
+			r = newPNGReader(r, f.Colors, f.BitsPerComponent, f.Columns)
 			readers = append(readers, r)
-		} else {
-			return nil, fmt.Errorf("unknown stream filter: %s", f)
+		case f.Predictor != 1:
+			return nil, fmt.Errorf("unsupported %s predictor: %d", f.Name, f.Predictor)
 		}
 	}
 	return &MultiCloser{
@@ -57,64 +165,38 @@ func extractStream(r io.ReadCloser, filters []string) (io.ReadCloser, error) {
 	}, nil
 }
 
-// walk traverses a pdf.Value graph while avoiding cycles by tracking object
-// pointers. callback is invoked for each visited value, in pre-order. If the
-// callback returns an error, the traversal stops and the error is forwarded to
-// the caller.
-func walk(root pdf.Value, callback func(v pdf.Value) error) error {
-	seen := map[uint32]struct{}{}
-	var walkNode func(pdf.Value, int) error
-	walkNode = func(v pdf.Value, depth int) error {
-		err := callback(v)
-		if err != nil {
-			return err
-		}
-		switch v.Kind() {
-		case pdf.Dict:
-			for _, k := range v.Keys() {
-				id := v.KeyId(k)
-				if _, ok := seen[id]; ok {
-					continue
-				}
-				seen[id] = struct{}{}
-				err := walkNode(v.Key(k), depth+1)
-				delete(seen, id)
-				if err != nil {
-					return err
-				}
-			}
-		case pdf.Array:
-			l := v.Len()
-			for i := 0; i < l; i++ {
-				id := v.IndexId(i)
-				if _, ok := seen[id]; ok {
-					continue
-				}
-				seen[id] = struct{}{}
-				err := walkNode(v.Index(i), depth+1)
-				delete(seen, id)
-				if err != nil {
-					return err
-				}
-			}
+const (
+	// maxErrorPrefix is the number of stream bytes reported with tokenizer
+	// errors.
+	maxErrorPrefix = 256
+)
+
+// prefixWriter keeps the first max bytes written to it and discards others.
+type prefixWriter struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (w *prefixWriter) Write(data []byte) (int, error) {
+	if n := w.max - w.buf.Len(); n > 0 {
+		if n > len(data) {
+			n = len(data)
 		}
-		return nil
+		w.buf.Write(data[:n])
 	}
-	return walkNode(root, 0)
+	return len(data), nil
 }
 
 // tokenize tokenizes a PDF actions stream ad invoke callback with the name and
 // the arguments of each extracted actions. The arguments are possible values
 // returned by pdf.Tokenize.
 func tokenize(r io.Reader, callback func(keyword string, args []interface{}) error) error {
-	data, err := ioutil.ReadAll(r)
+	// Keep the stream head to help diagnosing tokenizer errors
+	head := &prefixWriter{max: maxErrorPrefix}
+	tokens, err := pdf.Tokenize(io.TeeReader(r, head))
 	if err != nil {
-		return err
-	}
-	tokens, err := pdf.Tokenize(bytes.NewBuffer(data))
-	if err != nil {
-		ioutil.WriteFile("dump", data, 0644)
-		return fmt.Errorf("could not tokenize: %s", err)
+		return fmt.Errorf("could not tokenize stream starting with %q: %w",
+			head.buf.Bytes(), err)
 	}
 	args := []interface{}{}
 	for _, t := range tokens {
@@ -158,41 +240,243 @@ func f64(v interface{}) float64 {
 	return v.(float64)
 }
 
+// str returns the text carried by a show-text operand. pdf.Tokenize decodes
+// both literal "(...)" and hexadecimal "<...>" strings into Go strings, raw
+// byte slices are accepted as well.
+func str(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case []byte:
+		return string(s), true
+	}
+	return "", false
+}
+
+// flatten returns operands with nested arrays expanded in place.
+func flatten(args []interface{}) []interface{} {
+	flat := []interface{}{}
+	for _, arg := range args {
+		if a, ok := arg.([]interface{}); ok {
+			flat = append(flat, flatten(a)...)
+		} else {
+			flat = append(flat, arg)
+		}
+	}
+	return flat
+}
+
+// nameArg returns the name carried by a PDF name operand, without its
+// leading slash.
+func nameArg(v interface{}) string {
+	return strings.TrimPrefix(fmt.Sprint(v), "/")
+}
+
+// matrix is a PDF transformation matrix [a b c d e f].
+type matrix [6]float64
+
+var (
+	identity = matrix{1, 0, 0, 1, 0, 0}
+)
+
+// mul returns the product of m by n, that is m applied before n.
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+// apply transforms the (x, y) point by m.
+func (m matrix) apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
 type Line struct {
 	Value string
 	Words []Word
 }
 
 // extractStreamLines parses a PDF action stream, extract text bits and attemps
-// to group them by line using the text matrices offsets. It returns a sequence
-// of lines from top to bottom.
-func extractStreamLines(r io.Reader) ([]Line, error) {
+// to group them by line using the text matrices offsets. Form XObjects painted
+// with the Do operator are looked up in resources and extracted in place.
+// Text is mapped to Unicode with the ToUnicode CMap of the current font. TJ
+// positioning adjustments preceding the first glyphs are scaled by the font
+// size and the horizontal Tz scaling, which does not apply to the text matrix
+// origin and is saved and restored with the graphics state. Rows
+// within cfg.RowTolerance of each other are grouped in the same line, whose
+// words are sorted by column then stream order. It returns a sequence of
+// lines from top to bottom.
+func extractStreamLines(cfg *ParserConfig, r io.Reader, resources pdf.Value) ([]Line, error) {
 	lines := map[float64][]Word{}
-	x, y := 0., 0.
-	text := false
-	err := tokenize(r, func(keyword string, args []interface{}) error {
-		switch keyword {
-		case "BT": // Begin text object
-			text = true
-		case "ET": // End text object
-			text = false
-		case "Tj": // Show text
-			lines[y] = append(lines[y], Word{
-				Column: x,
-				S:      args[0].(string),
+	seen := map[uint32]struct{}{}
+	var extract func(r io.Reader, resources pdf.Value, ctm matrix) error
+	extract = func(r io.Reader, resources pdf.Value, ctm matrix) error {
+		// Text and text line matrices
+		tm, tlm := identity, identity
+		leading := 0.
+		fontSize := 0.
+		// Horizontal scaling set by Tz, as a fraction
+		scale := 1.
+		// Saved graphics states transformation matrices and scalings
+		type state struct {
+			ctm   matrix
+			scale float64
+		}
+		states := []state{}
+		text := false
+		// ToUnicode CMaps of resources fonts, by resource name
+		cmaps := map[string]*CMap{}
+		var cmap *CMap
+		nextLine := func() {
+			tlm = matrix{1, 0, 0, 1, 0, -leading}.mul(tlm)
+			tm = tlm
+		}
+		// show adds s as a word displaced horizontally by dx in text space
+		show := func(s string, dx float64) {
+			// Text can only be shown inside text objects
+			if !text {
+				return
+			}
+			if cmap != nil {
+				s = cmap.Decode(s)
+			}
+			col, row := ctm.apply(tm.apply(dx, 0))
+			lines[row] = append(lines[row], Word{
+				Column: col,
+				S:      s,
 			})
-		case "Tm": // set text matrix
-			x = f64(args[4])
-			y = f64(args[5])
 		}
-		return nil
-	})
+		return tokenize(r, func(keyword string, args []interface{}) error {
+			switch keyword {
+			case "BT": // Begin text object, matrices start from identity
+				text = true
+				tm, tlm = identity, identity
+			case "ET": // End text object
+				text = false
+			case "Tj": // Show text
+				s, ok := str(args[0])
+				if !ok {
+					return fmt.Errorf("invalid Tj operand: %v", args[0])
+				}
+				show(s, 0)
+			case "TJ": // Show text with individual glyph positioning
+				parts := []string{}
+				dx := 0.
+				for _, arg := range flatten(args) {
+					if s, ok := str(arg); ok {
+						parts = append(parts, s)
+					} else if len(parts) == 0 {
+						// Numbers are adjustments in thousandths of text
+						// space units, only leading ones move the word
+						dx -= f64(arg) / 1000 * fontSize * scale
+					}
+				}
+				if len(parts) > 0 {
+					show(strings.Join(parts, ""), dx)
+				}
+			case "q": // Save graphics state
+				states = append(states, state{ctm, scale})
+			case "Q": // Restore graphics state
+				if len(states) > 0 {
+					ctm, scale = states[len(states)-1].ctm, states[len(states)-1].scale
+					states = states[:len(states)-1]
+				}
+			case "cm": // Concatenate matrix to current transformation matrix
+				m := matrix{}
+				for i := range m {
+					m[i] = f64(args[i])
+				}
+				ctm = m.mul(ctm)
+			case "Tm": // set text matrix
+				for i := range tm {
+					tm[i] = f64(args[i])
+				}
+				tlm = tm
+			case "Td", "TD": // Move to next line start, TD also sets leading
+				tx, ty := f64(args[0]), f64(args[1])
+				if keyword == "TD" {
+					leading = -ty
+				}
+				tlm = matrix{1, 0, 0, 1, tx, ty}.mul(tlm)
+				tm = tlm
+			case "Tf": // Set text font and size
+				name := nameArg(args[0])
+				fontSize = f64(args[1])
+				m, ok := cmaps[name]
+				if !ok {
+					var err error
+					m, err = fontCMap(resources.Key("Font").Key(name))
+					if err != nil {
+						return fmt.Errorf("could not read font %s ToUnicode: %w", name, err)
+					}
+					cmaps[name] = m
+				}
+				cmap = m
+			case "TL": // Set text leading
+				leading = f64(args[0])
+			case "Tz": // Set horizontal scaling, in percents
+				scale = f64(args[0]) / 100
+			case "T*": // Move to next line start using leading
+				nextLine()
+			case "'", "\"": // Move to next line and show text
+				// " also sets word and character spacing first
+				if len(args) == 0 {
+					return fmt.Errorf("missing %s operand", keyword)
+				}
+				arg := args[len(args)-1]
+				s, ok := str(arg)
+				if !ok {
+					return fmt.Errorf("invalid %s operand: %v", keyword, arg)
+				}
+				nextLine()
+				show(s, 0)
+			case "Do": // Paint XObject
+				xobjects := resources.Key("XObject")
+				name := nameArg(args[0])
+				form := xobjects.Key(name)
+				if form.Kind() != pdf.Stream || form.Key("Subtype").Name() != "Form" {
+					return nil
+				}
+				id := xobjects.KeyId(name)
+				if _, ok := seen[id]; ok {
+					return nil
+				}
+				seen[id] = struct{}{}
+				defer delete(seen, id)
+				fr, err := openStream(form)
+				if err != nil || fr == nil {
+					return err
+				}
+				defer fr.Close()
+				m := identity
+				if fm := form.Key("Matrix"); fm.Len() == len(m) {
+					for i := range m {
+						m[i] = fm.Index(i).Float64()
+					}
+				}
+				res := form.Key("Resources")
+				if res.Kind() != pdf.Dict {
+					res = resources
+				}
+				err = extract(fr, res, m.mul(ctm))
+				if err != nil {
+					return fmt.Errorf("could not extract form %s: %w", name, err)
+				}
+			}
+			return nil
+		})
+	}
+	err := extract(r, resources, identity)
 	if err != nil {
 		return nil, err
 	}
 	ys := []float64{}
-	for y, words := range lines {
-		sort.Sort(sortedWords(words))
+	for y := range lines {
 		ys = append(ys, y)
 	}
 	sort.Float64s(ys)
@@ -200,15 +484,26 @@ func extractStreamLines(r io.Reader) ([]Line, error) {
 		j := len(ys) - i - 1
 		ys[i], ys[j] = ys[j], ys[i]
 	}
+	// Group rows from the top row of each line
+	rows := [][]Word{}
+	top := 0.
+	for i, y := range ys {
+		if i == 0 || top-y > cfg.RowTolerance {
+			rows = append(rows, nil)
+			top = y
+		}
+		rows[len(rows)-1] = append(rows[len(rows)-1], lines[y]...)
+	}
 	result := []Line{}
-	for _, y := range ys {
+	for _, words := range rows {
+		sort.Stable(sortedWords(words))
 		parts := []string{}
-		for _, w := range lines[y] {
+		for _, w := range words {
 			parts = append(parts, w.S)
 		}
 		result = append(result, Line{
 			Value: strings.Join(parts, " "),
-			Words: lines[y],
+			Words: words,
 		})
 	}
 	return result, err
@@ -216,55 +511,263 @@ func extractStreamLines(r io.Reader) ([]Line, error) {
 
 // Op represent a line in the bank report. They come in two kinds: account
 // state if IsTotal is true, account change otherwise. Value is expressed in
-// eurocents. Date is unstructured and depends on the type of record. Source is
-// the entry lable and SourceCol its column location in the PDF page.
+// minor units, see ParserConfig.CurrencyScale. Date is unstructured and
+// depends on the type of record. Source is the entry lable and SourceCol its
+// column location in the PDF page.
+// Reference is the bank operation reference, when one was found in the label.
+// Foreign currency operations carry their unsigned original amount in
+// OriginalValue, in minor units of OriginalCurrency.
 type Op struct {
-	Date      string
-	Source    string
-	SourceCol float64
-	Value     int64
-	HasValue  bool
-	IsTotal   bool
+	Date             string
+	Source           string
+	SourceCol        float64
+	Value            int64
+	HasValue         bool
+	IsTotal          bool
+	Reference        string
+	OriginalValue    int64
+	OriginalCurrency string
+	// Words are the words Source was built from.
+	Words []Word
+	// Currency is the statement currency declared on the page, if any.
+	Currency string
+	// Stream identifies the content stream the operation was read from and
+	// Occurrence counts identical operations preceding it in this stream.
+	Stream     string
+	Occurrence int
+	// Continued is true for amount lines found before any operation of their
+	// stream, which may complete an operation started on the previous page.
+	Continued bool
 }
 
 var (
-	reDigits = regexp.MustCompile(`^\d+$`)
+	reDigits    = regexp.MustCompile(`^\d+$`)
+	reReference = regexp.MustCompile(`^\d{10,}$`)
+	reDayMonth  = regexp.MustCompile(`^\d{2}$`)
 )
 
-// stripValue takes a []Word, attemps to extract a trailing amount like
-// "123,45" or "12.345,67" and returns the stripped words and success.
-func stripValue(line string, words []Word) ([]Word, int64, bool) {
+var (
+	reCurrency = regexp.MustCompile(`^[A-Z]{3}$`)
+	// reStatementCurrency matches statement headers declaring the accounts
+	// currency, like "Devise : EUR" or "montants en euros".
+	reStatementCurrency = regexp.MustCompile(
+		`(?i)\b(?:devise|currency)\s*:\s*([A-Z]{3})\b|\b(?:en|in) (euro)s?\b`)
+)
+
+// findStatementCurrency returns the ISO code of the currency declared in
+// lines, or an empty string.
+func findStatementCurrency(lines []Line) string {
+	for _, line := range lines {
+		m := reStatementCurrency.FindStringSubmatch(line.Value)
+		if m == nil {
+			continue
+		}
+		if m[1] != "" {
+			return strings.ToUpper(m[1])
+		}
+		return "EUR"
+	}
+	return ""
+}
+
+// findForeignAmount looks for an amount followed by a currency code like
+// "50,00 USD" in words. It returns the amount in minor units and the
+// currency, or an empty currency if there is none.
+func findForeignAmount(cfg *ParserConfig, words []Word) (int64, string) {
+	for i := 0; i+3 < len(words); i++ {
+		head := words[i].S
+		comma := words[i+1].S
+		tail := words[i+2].S
+		cur := words[i+3].S
+		if !reDigits.MatchString(head) || comma != cfg.DecimalSep ||
+			!reDigits.MatchString(tail) ||
+			len(tail) != cfg.CurrencyScale || !reCurrency.MatchString(cur) || cur == "EUR" {
+			continue
+		}
+		v, err := strconv.ParseInt(head+tail, 10, 64)
+		if err != nil {
+			continue
+		}
+		return v, cur
+	}
+	return 0, ""
+}
+
+// stripReference extracts the first bank reference, a long sequence of
+// digits, from words and returns the remaining words.
+func stripReference(words []Word) ([]Word, string) {
+	for i, w := range words {
+		if reReference.MatchString(w.S) {
+			kept := append([]Word{}, words[:i]...)
+			kept = append(kept, words[i+1:]...)
+			return kept, w.S
+		}
+	}
+	return words, ""
+}
+
+// Statement types, see ParserConfig.Statement.
+const (
+	AutoStatement     = "auto"
+	CheckingStatement = "checking"
+	LivretStatement   = "livret"
+)
+
+// ParserConfig holds statements layout parameters.
+type ParserConfig struct {
+	// SignColumn is the column left of which amounts are debits, and
+	// credits otherwise.
+	SignColumn float64
+	// SignBands infers the sign column of each page by splitting its
+	// amounts columns in two bands, debits then credits. SignColumn is used
+	// when there are not two distinct bands.
+	SignBands bool
+	// WordSeparator is inserted between words when building operations
+	// sources.
+	WordSeparator string
+	// DecimalSep separates amounts units from minor units, GroupingSep their
+	// thousands groups. Both are words of their own in extracted lines.
+	DecimalSep  string
+	GroupingSep string
+	// Statement is the statement type, AutoStatement detects it from each
+	// stream content.
+	Statement string
+	// Verbose logs parsing progress and matched markers.
+	Verbose bool
+	// CurrencyScale is the number of decimal digits of amounts, values are
+	// expressed in these minor units.
+	CurrencyScale int
+	// MaxPages limits the number of pages processed in each report, if
+	// positive.
+	MaxPages int
+	// RowTolerance is the largest vertical distance between words of the
+	// same line.
+	RowTolerance float64
+	// Locale holds the statements labels.
+	Locale *Locale
+	// ColumnTolerance is the largest distance between an operation source
+	// column and the most popular one on its page.
+	ColumnTolerance float64
+	// From and To restrict returned values to this dates range, once
+	// reports are reconciled. Zero dates are ignored.
+	From time.Time
+	To   time.Time
+	// Tolerance is the largest difference, in minor units, accepted between
+	// an account record and the running total of operations. Accepted
+	// differences are reported on stderr and the account record prevails.
+	Tolerance int64
+	// KeepGoing makes failed files and reports skipped instead of aborting
+	// the extraction.
+	KeepGoing bool
+	// DedupReferences identifies operations by their bank reference, when
+	// they have one, instead of their date, source and value.
+	DedupReferences bool
+}
+
+const (
+	// minBandGap is the minimum distance between debits and credits columns
+	// bands.
+	minBandGap = 50
+)
+
+// logf logs its arguments with the standard logger, in verbose mode only.
+func (cfg *ParserConfig) logf(format string, args ...interface{}) {
+	if cfg.Verbose {
+		log.Printf(format, args...)
+	}
+}
+
+// NewParserConfig returns a ParserConfig suitable for BNP Paribas checking
+// account statements.
+func NewParserConfig() *ParserConfig {
+	return &ParserConfig{
+		SignColumn:    500,
+		WordSeparator: " ",
+		DecimalSep:    ",",
+		GroupingSep:   ".",
+		RowTolerance:  0.5,
+		CurrencyScale: defaultCurrencyScale,
+		Statement:     AutoStatement,
+		Locale:        locales["fr"],
+	}
+}
+
+// findValue attemps to find a trailing amount like "123,45", "12.345,67" or
+// "1.234.567,89" in words, with separators set by cfg. It returns the number
+// of words making the amount and its unsigned value in minor units.
+func findValue(cfg *ParserConfig, words []Word) (int, int64, bool) {
 	if len(words) < 3 {
-		return words, 0, false
+		return 0, 0, false
 	}
 	lw := len(words)
 	head := words[lw-3].S
 	dot := words[lw-2].S
 	tail := words[lw-1].S
 	// 123,45
-	if reDigits.MatchString(head) && dot == "," && reDigits.MatchString(tail) &&
-		len(tail) == 2 {
+	if reDigits.MatchString(head) && dot == cfg.DecimalSep && reDigits.MatchString(tail) &&
+		len(tail) == cfg.CurrencyScale {
 		n := 3
 		num := head + tail
-		// 3.123.45
-		if lw > 4 && words[lw-4].S == "." && reDigits.MatchString(words[lw-5].S) {
-			num = words[lw-5].S + num
-			n = 5
+		// 1.234.567,89, groups after a separator have three digits
+		for lw-n >= 2 && len(words[lw-n].S) == 3 && words[lw-n-1].S == cfg.GroupingSep &&
+			reDigits.MatchString(words[lw-n-2].S) {
+			num = words[lw-n-2].S + num
+			n += 2
 		}
 		v, err := strconv.ParseInt(num, 10, 64)
 		if err != nil {
-			return words, 0, false
+			return 0, 0, false
+		}
+		return n, v, true
+	}
+	return 0, 0, false
+}
+
+// stripValue takes a []Word, attemps to extract a trailing amount like
+// "123,45" or "12.345,67" and returns the stripped words and success.
+func stripValue(cfg *ParserConfig, line string, words []Word) ([]Word, int64, bool) {
+	n, v, ok := findValue(cfg, words)
+	if !ok {
+		return words, 0, false
+	}
+	lw := len(words)
+	if words[lw-n].Column < cfg.SignColumn {
+		v = -v
+	}
+	return words[:lw-n], v, true
+}
+
+// amountColumns returns the column of every trailing amount in lines.
+func amountColumns(cfg *ParserConfig, lines []Line) []float64 {
+	cols := []float64{}
+	for _, line := range lines {
+		n, _, ok := findValue(cfg, line.Words)
+		if ok {
+			cols = append(cols, line.Words[len(line.Words)-n].Column)
 		}
-		if words[lw-n].Column < 500 {
-			v = -v
+	}
+	return cols
+}
+
+// splitBands looks for two bands of columns, separated by a gap of at least
+// minGap, and returns the middle of the largest such gap.
+func splitBands(cols []float64, minGap float64) (float64, bool) {
+	sorted := append([]float64{}, cols...)
+	sort.Float64s(sorted)
+	split, gap := 0., 0.
+	for i := 1; i < len(sorted); i++ {
+		if d := sorted[i] - sorted[i-1]; d > gap {
+			gap = d
+			split = (sorted[i] + sorted[i-1]) / 2
 		}
-		return words[:lw-n], v, true
 	}
-	return words, 0, false
+	return split, gap >= minGap
 }
 
 // stripDate attemps to extract a leading date like "13.06" and returns the
-// stripped words on success.
+// stripped words on success. Day and month must be made of two digits and
+// fall in valid ranges, so amounts fragments like "12.345" or "99.99" are left
+// alone.
 func stripDate(line string, words []Word) ([]Word, string) {
 	lw := len(words)
 	if lw < 3 {
@@ -273,37 +776,108 @@ func stripDate(line string, words []Word) ([]Word, string) {
 	head := words[0].S
 	dot := words[1].S
 	tail := words[2].S
-	if !reDigits.MatchString(head) || dot != "." || !reDigits.MatchString(tail) {
+	if !reDayMonth.MatchString(head) || dot != "." || !reDayMonth.MatchString(tail) {
+		return words, ""
+	}
+	day, _ := strconv.Atoi(head)
+	month, _ := strconv.Atoi(tail)
+	if day < 1 || day > 31 || month < 1 || month > 12 {
 		return words, ""
 	}
 	return words[3:], words[0].S + words[1].S + words[2].S
 }
 
-func joinWords(words []Word) string {
+func joinWords(cfg *ParserConfig, words []Word) string {
 	parts := []string{}
 	for _, w := range words {
 		parts = append(parts, w.S)
 	}
-	return strings.Join(parts, " ")
+	return strings.Join(parts, cfg.WordSeparator)
+}
+
+// Locale holds the statement labels which depend on its language.
+type Locale struct {
+	// Start matches account state lines, capturing their date.
+	Start *regexp.Regexp
+	// Summary prefixes the operations totals line, which is skipped.
+	Summary string
+	// Footers prefix lines ending the operations list of a stream.
+	Footers []string
 }
 
 var (
-	reStart = regexp.MustCompile(`^SOLDE\s+.*(\d{2}\.\d{2}\.\d{4})`)
+	// locales maps --locale names to statements labels.
+	locales = map[string]*Locale{
+		"fr": {
+			Start:   regexp.MustCompile(`^SOLDE\s+.*(\d{2}\.\d{2}\.\d{4})`),
+			Summary: "TOTAL DES MONTANTS",
+			Footers: []string{
+				"BNP PARIBAS SA : capital de",
+				"Montant de votre autorisation",
+			},
+		},
+		"en": {
+			Start:   regexp.MustCompile(`^BALANCE\s+.*(\d{2}\.\d{2}\.\d{4})`),
+			Summary: "TOTAL OF AMOUNTS",
+			Footers: []string{
+				"BNP PARIBAS SA : capital of",
+				"Amount of your overdraft",
+			},
+		},
+	}
+)
+
+var (
+	reLivret   = regexp.MustCompile(`^LIVRET\b`)
+	reInterest = regexp.MustCompile(`^(?:INTERETS|CAPITALISATION)\b`)
 )
 
+// isLivret returns true if lines look like a savings account (Livret)
+// statement rather than a checking account one.
+func isLivret(lines []Line) bool {
+	for _, line := range lines {
+		if reLivret.MatchString(line.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseInterestOp turns a Livret interest capitalization Op into a standalone
+// credit. Interests are never debited and their line may lack a date, in
+// which case the previous operation day is used. It returns false if op is
+// not an interest line.
+func parseInterestOp(op, prev *Op) bool {
+	if !op.HasValue || !reInterest.MatchString(op.Source) {
+		return false
+	}
+	if op.Value < 0 {
+		op.Value = -op.Value
+	}
+	if op.Date == "" {
+		if prev == nil || len(prev.Date) < 5 {
+			return false
+		}
+		// Keep "dd.mm" from account records dates
+		op.Date = prev.Date[:5]
+	}
+	return true
+}
+
 // parseTotalLine attempts to parse an account state line. It returns a nil Op
 // if the line does not look like it, or an error.
-func parseTotalLine(line Line) (*Op, error) {
-	m := reStart.FindStringSubmatch(line.Value)
+func parseTotalLine(cfg *ParserConfig, line Line) (*Op, error) {
+	m := cfg.Locale.Start.FindStringSubmatch(line.Value)
 	if m == nil {
 		return nil, nil
 	}
-	w, v, ok := stripValue(line.Value, line.Words)
+	w, v, ok := stripValue(cfg, line.Value, line.Words)
 	if !ok {
 		return nil, fmt.Errorf("could not parse total line: %s", line.Value)
 	}
 	return &Op{
-		Source:    joinWords(w),
+		Source:    joinWords(cfg, w),
+		Words:     w,
 		SourceCol: -1,
 		Date:      m[1],
 		Value:     v,
@@ -322,7 +896,7 @@ func parseTotalLine(line Line) (*Op, error) {
 //
 // Returned Op can be partial, that is have only a date and source, only a
 // source or only a source and value.
-func parseOpLine(line Line) (*Op, error) {
+func parseOpLine(cfg *ParserConfig, line Line) (*Op, error) {
 	op := &Op{}
 	words := line.Words
 	w, date := stripDate(line.Value, words)
@@ -330,47 +904,78 @@ func parseOpLine(line Line) (*Op, error) {
 	if date != "" {
 		op.Date = date
 	}
-	w, v, offset := stripValue(line.Value, words)
+	w, v, offset := stripValue(cfg, line.Value, words)
 	words = w
 	if offset {
 		op.Value = v
 		op.HasValue = true
 	}
-	w, v, offset = stripValue(line.Value, words)
+	w, v, offset = stripValue(cfg, line.Value, words)
 	if offset {
 		// Invalid summary "TOTAL DES MONTANTS" line
 		return nil, nil
 	}
+	words, op.Reference = stripReference(words)
+	op.OriginalValue, op.OriginalCurrency = findForeignAmount(cfg, words)
 	if len(words) > 0 {
 		op.SourceCol = words[0].Column
 	}
-	op.Source += joinWords(words)
+	op.Source += joinWords(cfg, words)
+	op.Words = words
 	return op, nil
 }
 
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyOp returns true if op carries nothing, like ops parsed from blank
+// lines.
+func isEmptyOp(op *Op) bool {
+	return !op.IsTotal && !op.HasValue && op.Date == "" && op.Source == "" &&
+		op.Reference == "" && op.OriginalCurrency == ""
+}
+
 // parseOps returns a sequence of Ops extracted from a single stream. Partial
-// operations are consolidated.
-func parseOps(lines []Line) ([]*Op, error) {
+// operations are consolidated. Livret statements, detected or selected by
+// cfg.Statement, have their interest lines recognized and always accounted
+// as credits. Lines carrying an amount before any operation are returned as
+// Continued operations, to be merged by extractPDFOps.
+func parseOps(cfg *ParserConfig, lines []Line) ([]*Op, error) {
+	livret := cfg.Statement == LivretStatement ||
+		cfg.Statement == AutoStatement && isLivret(lines)
+	if livret {
+		cfg.logf("livret statement")
+	}
 	ops := []*Op{}
 	for _, line := range lines {
-		if strings.HasPrefix(line.Value, "TOTAL DES MONTANTS") {
+		if strings.HasPrefix(line.Value, cfg.Locale.Summary) {
+			cfg.logf("summary line: %s", line.Value)
 			continue
 		}
-		if strings.HasPrefix(line.Value, "BNP PARIBAS SA : capital de") ||
-			strings.HasPrefix(line.Value, "Montant de votre autorisation") {
+		if hasAnyPrefix(line.Value, cfg.Locale.Footers) {
+			cfg.logf("footer line: %s", line.Value)
 			break
 		}
-		op, err := parseTotalLine(line)
+		op, err := parseTotalLine(cfg, line)
 		if err != nil {
 			return nil, err
 		}
+		if op != nil {
+			cfg.logf("account record: %s", line.Value)
+		}
 		if op == nil {
-			op, err = parseOpLine(line)
+			op, err = parseOpLine(cfg, line)
 			if err != nil {
 				return nil, err
 			}
 		}
-		if op == nil {
+		if op == nil || isEmptyOp(op) {
 			continue
 		}
 		var prev *Op
@@ -378,35 +983,56 @@ func parseOps(lines []Line) ([]*Op, error) {
 			prev = ops[len(ops)-1]
 		}
 
-		if op.Date != "" {
+		if livret && !op.IsTotal && parseInterestOp(op, prev) {
+			ops = append(ops, op)
+		} else if op.Date != "" {
 			// Append
 			ops = append(ops, op)
-		} else {
-			if prev != nil && (op.HasValue || op.Source != "") {
-				// Merge
-				if op.HasValue {
-					prev.Value = op.Value
-					prev.HasValue = true
-				}
-				if op.Source != "" && op.SourceCol == prev.SourceCol {
-					prev.Source += op.Source
-				}
+		} else if prev == nil || prev.Continued {
+			if op.HasValue {
+				op.Continued = true
+				ops = append(ops, op)
 			}
+		} else if op.HasValue || op.Source != "" {
+			mergeOp(cfg, prev, op)
 		}
 	}
 	return ops, nil
 }
 
+// mergeOp merges continuation line op into prev. Sources are merged if
+// aligned on prev source, amounts if prev does not have one yet.
+func mergeOp(cfg *ParserConfig, prev, op *Op) {
+	aligned := op.Source == "" ||
+		math.Abs(op.SourceCol-prev.SourceCol) <= cfg.ColumnTolerance
+	if op.HasValue && aligned && !prev.HasValue {
+		prev.Value = op.Value
+		prev.HasValue = true
+	}
+	if op.Source != "" && aligned {
+		prev.Source += op.Source
+		prev.Words = append(prev.Words, op.Words...)
+	}
+	if op.Reference != "" && prev.Reference == "" {
+		prev.Reference = op.Reference
+	}
+	if op.OriginalCurrency != "" && prev.OriginalCurrency == "" {
+		prev.OriginalValue = op.OriginalValue
+		prev.OriginalCurrency = op.OriginalCurrency
+	}
+}
+
 // filterOnSourceColumn assumes the Ops are either account states or changes,
 // and that changes are always formatted like described in parseOpLine. Using
 // the most popular SourceCol it then weeds out lines looking like changes
-// which are not.
-func filterOnSourceColumn(ops []*Op) []*Op {
+// which are not. Columns within cfg.ColumnTolerance of the most popular one
+// are kept as well, so are Continued operations.
+func filterOnSourceColumn(cfg *ParserConfig, ops []*Op) []*Op {
 	cols := map[float64]int{}
 	maxCol := float64(-1)
 	maxCount := -1
 	for _, op := range ops {
-		if op.SourceCol >= 0 {
+		if op.SourceCol >= 0 && !op.Continued {
 			n := cols[op.SourceCol] + 1
 			if n > maxCount {
 				maxCount = n
@@ -417,126 +1043,367 @@ func filterOnSourceColumn(ops []*Op) []*Op {
 	}
 	kept := []*Op{}
 	for _, op := range ops {
-		if op.SourceCol < 0 || op.SourceCol == maxCol {
+		if op.SourceCol < 0 || op.Continued ||
+			math.Abs(op.SourceCol-maxCol) <= cfg.ColumnTolerance {
 			kept = append(kept, op)
 		}
 	}
 	return kept
 }
 
-// extractOps returns all operations from a single page pdf.Value, filtered.
-func extractOps(v pdf.Value) ([]*Op, error) {
-	allOps := []*Op{}
-	err := walk(v, func(v pdf.Value) error {
+// openStream returns a reader on the decoded content of stream v, or nil for
+// streams which cannot carry text like images or fonts.
+func openStream(v pdf.Value) (io.ReadCloser, error) {
+	// Only for Type1/TrueType fonts
+	if !v.Key("Length1").IsNull() ||
+		v.Key("Subtype").Name() == "Image" {
+		return nil, nil
+	}
+	return extractStream(v.Reader(), pdfwalk.StreamFilters(v))
+}
+
+// extractPageLines returns the lines of every content stream of page, whose
+// number is num.
+func extractPageLines(cfg *ParserConfig, page pdf.Page, num int) ([][]Line, error) {
+	streams := [][]Line{}
+	resources := page.Resources()
+	err := pdfwalk.Walk(page.V, func(v pdf.Value) error {
 		if v.Kind() != pdf.Stream {
 			return nil
 		}
-		filters := []string{}
-		for _, k := range v.Keys() {
-			// Only for Type1/TrueType fonts
-			if k == "Length1" ||
-				k == "Subtype" && v.Key(k).Name() == "Image" {
-				return nil
-			}
-			if k != "Filters" {
-				continue
-			}
-			values := v.Key(k)
-			l := values.Len()
-			for i := 0; i < l; i++ {
-				filters = append(filters, values.Index(i).Name())
-			}
+		// Form XObjects are extracted where the page paints them
+		if v.Key("Subtype").Name() == "Form" {
+			return nil
 		}
-		r, err := extractStream(v.Reader(), filters)
+		r, err := openStream(v)
 		if err != nil {
-			return err
+			return fmt.Errorf("page %d: %w", num, err)
+		}
+		if r == nil {
+			return nil
 		}
-		lines, err := extractStreamLines(r)
+		lines, err := extractStreamLines(cfg, r, resources)
 		r.Close()
 		if err != nil {
 			headers := &bytes.Buffer{}
 			for _, k := range v.Keys() {
 				fmt.Fprintf(headers, "%s: %s\n", k, v.Key(k))
 			}
-			return fmt.Errorf("could not parse stream: %s\n%s\n", err, headers.String())
+			return fmt.Errorf("page %d: could not parse stream: %w\n%s\n", num, err,
+				headers.String())
 		}
-		ops, err := parseOps(lines)
-		if err != nil {
-			return err
-		}
-		allOps = append(allOps, ops...)
+		streams = append(streams, lines)
 		return nil
 	})
-	return filterOnSourceColumn(allOps), err
+	return streams, err
 }
 
-func hashOp(op *Op) string {
-	return op.Date + "-" + op.Source + "-" + fmt.Sprintf("%f", op.Value)
+// hasWords returns true if any line of streams contains a word.
+func hasWords(streams [][]Line) bool {
+	for _, lines := range streams {
+		for _, line := range lines {
+			if len(line.Words) > 0 {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-// extractPDFOps returns all operations in a PDF report, deduplicated.
-func extractPDFOps(r *pdf.Reader) ([]*Op, error) {
-	seen := map[string]bool{}
-	pages := r.NumPage()
+// extractOps returns all operations from the page numbered num of r,
+// filtered. The page is looked up holding mu, its streams are then decoded
+// and parsed without it, as r only reads its file with ReadAt. Returned
+// errors are prefixed with the page number.
+func extractOps(cfg *ParserConfig, r *pdf.Reader, mu *sync.Mutex, num int) ([]*Op, error) {
+	mu.Lock()
+	page := r.Page(num)
+	mu.Unlock()
+	streams, err := extractPageLines(cfg, page, num)
+	if err != nil {
+		return nil, err
+	}
+	if !hasWords(streams) {
+		// Scanned statements only contain images
+		fmt.Fprintf(os.Stderr, "warning: page %d: no text found, it may need OCR\n",
+			num)
+	}
+	if cfg.SignBands {
+		cols := []float64{}
+		for _, lines := range streams {
+			cols = append(cols, amountColumns(cfg, lines)...)
+		}
+		if split, ok := splitBands(cols, minBandGap); ok {
+			pageCfg := *cfg
+			pageCfg.SignColumn = split
+			cfg = &pageCfg
+		}
+	}
+	currency := ""
+	for _, lines := range streams {
+		currency = findStatementCurrency(lines)
+		if currency != "" {
+			break
+		}
+	}
 	allOps := []*Op{}
-	for i := 0; i < pages; i++ {
-		ops, err := extractOps(r.Page(i + 1).V)
+	lineCount := 0
+	for _, lines := range streams {
+		lineCount += len(lines)
+		ops, err := parseOps(cfg, lines)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("page %d: %w", num, err)
 		}
+		locateOps(ops, lines)
 		for _, op := range ops {
-			h := hashOp(op)
-			if seen[h] {
-				continue
-			}
-			seen[h] = true
-			allOps = append(allOps, op)
+			op.Currency = currency
 		}
+		allOps = append(allOps, ops...)
 	}
-	return allOps, nil
-}
-
-// Value is the state of an account at a given date, after applying the
-// operation described by Source. Value is in eurocents.
-type Value struct {
-	Date   time.Time
-	Source string
-	Value  int64
+	kept := filterOnSourceColumn(cfg, allOps)
+	cfg.logf("page %d: %d streams, %d lines, %d operations, %d kept", num,
+		len(streams), lineCount, len(allOps), len(kept))
+	return kept, nil
+}
+
+// locateOps sets the Stream and Occurrence of ops parsed from lines. Streams
+// are identified by their content, since the same stream can be reached from
+// several pages.
+func locateOps(ops []*Op, lines []Line) {
+	h := sha1.New()
+	for _, line := range lines {
+		io.WriteString(h, line.Value)
+		h.Write([]byte{0})
+	}
+	stream := fmt.Sprintf("%x", h.Sum(nil))[:16]
+	counts := map[string]int{}
+	for _, op := range ops {
+		op.Stream = stream
+		k := op.Date + "-" + op.Source + "-" + strconv.FormatInt(op.Value, 10)
+		op.Occurrence = counts[k]
+		counts[k]++
+	}
+}
+
+// hashOp returns a key identifying op within a report. Identical operations
+// are told apart by their location, so only operations read multiple times
+// from the same stream are considered equal. With cfg.DedupReferences, the
+// bank reference is preferred when available as it does not depend on the
+// label rendering.
+func hashOp(cfg *ParserConfig, op *Op) string {
+	if cfg.DedupReferences && op.Reference != "" {
+		return "ref-" + op.Reference
+	}
+	return fmt.Sprintf("%s-%s-%d-%s-%d", op.Date, op.Source, op.Value, op.Stream,
+		op.Occurrence)
+}
+
+const (
+	// progressPages is the number of pages between progress messages.
+	progressPages = 10
+)
+
+// extractPDFOps returns all operations in a PDF report, deduplicated. Pages
+// are parsed concurrently, operations are returned in page order. Only the
+// first cfg.MaxPages pages are processed if it is positive, and progress is
+// logged every progressPages pages. Operations whose amount lands on the next
+// page are completed by its Continued operations.
+func extractPDFOps(cfg *ParserConfig, r *pdf.Reader) ([]*Op, error) {
+	pages := r.NumPage()
+	if cfg.MaxPages > 0 && pages > cfg.MaxPages {
+		cfg.logf("only processing %d of %d pages", cfg.MaxPages, pages)
+		pages = cfg.MaxPages
+	}
+	done := int32(0)
+	pageOps := make([][]*Op, pages)
+	errs := make([]error, pages)
+	workers := runtime.GOMAXPROCS(0)
+	if workers > pages {
+		workers = pages
+	}
+	work := make(chan int)
+	mu := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for page := range work {
+				pageOps[page], errs[page] = extractOps(cfg, r, mu, page+1)
+				if n := atomic.AddInt32(&done, 1); n%progressPages == 0 {
+					cfg.logf("%d/%d pages processed", n, pages)
+				}
+			}
+		}()
+	}
+	for i := 0; i < pages; i++ {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	seen := map[string]bool{}
+	allOps := []*Op{}
+	for i, ops := range pageOps {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		for _, op := range ops {
+			h := hashOp(cfg, op)
+			if seen[h] {
+				continue
+			}
+			seen[h] = true
+			if op.Continued {
+				// Complete an operation pending at the end of the previous
+				// page or stream, discard it otherwise.
+				if n := len(allOps); n > 0 && !allOps[n-1].IsTotal &&
+					!allOps[n-1].HasValue {
+					mergeOp(cfg, allOps[n-1], op)
+				}
+				continue
+			}
+			allOps = append(allOps, op)
+		}
+	}
+	return allOps, nil
+}
+
+// Value is the state of an account at a given date, after applying the
+// operation described by Source. Value is in minor units of Currency, the
+// statement currency which defaults to EUR. Amount is the signed account
+// change of the operation, zero for account records unless they had to be
+// reconciled. Account optionally names the account the value belongs to.
+// Foreign currency operations report their signed original amount in
+// OriginalValue, in minor units of OriginalCurrency. Words optionally lists
+// the words Source was built from, with their columns. IsTotal is true for
+// account records, whose Value is read from the report instead of computed.
+type Value struct {
+	Date             time.Time
+	Source           string
+	Value            int64
+	Amount           int64
+	Account          string
+	Currency         string
+	Category         string `json:",omitempty"`
+	OriginalValue    int64  `json:",omitempty"`
+	OriginalCurrency string `json:",omitempty"`
+	Words            []Word `json:",omitempty"`
+	IsTotal          bool   `json:",omitempty"`
 }
 
 const (
 	dateFormat = "02.01.2006"
 )
 
+// parseDay parses a dateFormat day as midnight UTC. Values dates are always
+// UTC days, so they do not depend on the local time zone.
+func parseDay(s string) (time.Time, error) {
+	return time.ParseInLocation(dateFormat, s, time.UTC)
+}
+
+// utcDay returns midnight UTC of the calendar day of t, in its own location.
+func utcDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+const (
+	// maxYearGap bounds the number of years searched for an operation date.
+	// It covers the 8 years between some leap years.
+	maxYearGap = 8
+)
+
+// nextDate returns the first date matching the "dd.mm" day not before prev.
+// Years are incremented on year transitions, or until the day exists for
+// February 29th.
+func nextDate(day string, prev time.Time) (time.Time, error) {
+	var err error
+	for year := prev.Year(); year <= prev.Year()+maxYearGap; year++ {
+		var date time.Time
+		date, err = parseDay(fmt.Sprintf("%s.%d", day, year))
+		if err == nil && !prev.After(date) {
+			return date, nil
+		}
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, fmt.Errorf("no year found after %s",
+		prev.Format("2006-01-02"))
+}
+
+const (
+	// nearbyOps is the number of operations listed around invalid ones.
+	nearbyOps = 3
+)
+
+// describeOps formats ops on one line each, to report parsing errors.
+func describeOps(cfg *ParserConfig, ops []*Op) string {
+	buf := &bytes.Buffer{}
+	for _, op := range ops {
+		kind := "operation"
+		if op.IsTotal {
+			kind = "account record"
+		}
+		fmt.Fprintf(buf, "  %s: %q %q %s\n", kind, op.Date, op.Source,
+			formatAmount(op.Value, ".", cfg.CurrencyScale))
+	}
+	return buf.String()
+}
+
 // convertOptsToValues takes all operations of a report, check they start and
 // end with an account state entry, applies changes iteratively and check the
-// intermediate states match parsed states. Corresponding Values are returned.
-func convertOpsToValues(ops []*Op) ([]Value, error) {
+// intermediate states match parsed states, up to cfg.Tolerance. Corresponding
+// Values are returned.
+func convertOpsToValues(cfg *ParserConfig, ops []*Op) ([]Value, error) {
 	if len(ops) < 2 {
-		return nil, fmt.Errorf("not enough operations in report: %d", len(ops))
+		return nil, &NotEnoughOpsError{Count: len(ops)}
+	}
+	head, tail := ops, ops
+	if len(ops) > nearbyOps {
+		head, tail = ops[:nearbyOps], ops[len(ops)-nearbyOps:]
 	}
 	first := ops[0]
 	if !first.IsTotal {
-		return nil, fmt.Errorf("first operation is not an account record: %+v", *first)
+		return nil, fmt.Errorf("first operation is not an account record, the "+
+			"account state line probably did not match %s:\n%s",
+			cfg.Locale.Start, describeOps(cfg, head))
 	}
 	last := ops[len(ops)-1]
 	if !last.IsTotal {
-		return nil, fmt.Errorf("last operation is not an account record: %+v", *last)
+		return nil, fmt.Errorf("last operation is not an account record, the "+
+			"account state line probably did not match %s or the page footer "+
+			"was parsed as operations:\n%s", cfg.Locale.Start,
+			describeOps(cfg, tail))
 	}
 	values := []Value{}
 	total := first.Value
+	prevTotal := total
+	currency := "EUR"
 	for _, op := range ops {
+		if op.Currency != "" {
+			currency = op.Currency
+		}
 		var date time.Time
 		var err error
 		if op.IsTotal {
 			if op.Value != total {
-				return nil, fmt.Errorf(
-					"running total does not match account record %+v: %d != %d",
-					op, op.Value, total)
+				if abs(op.Value-total) > cfg.Tolerance {
+					return nil, &ReconcileError{
+						Op:       op,
+						Expected: op.Value,
+						Actual:   total,
+					}
+				}
+				fmt.Fprintf(os.Stderr, "warning: account record %s %q differs "+
+					"from operations total: %s != %s\n", op.Date, op.Source,
+					formatAmount(op.Value, ".", cfg.CurrencyScale),
+					formatAmount(total, ".", cfg.CurrencyScale))
+				total = op.Value
 			}
-			date, err = time.Parse(dateFormat, op.Date)
+			date, err = parseDay(op.Date)
 			if err != nil {
-				return nil, err
+				return nil, fmt.Errorf("invalid date %q in operation %q: %s",
+					op.Date, op.Source, err)
 			}
 		} else {
 			total += op.Value
@@ -544,85 +1411,475 @@ func convertOpsToValues(ops []*Op) ([]Value, error) {
 				return nil, fmt.Errorf("operation without an account record: %+v", op)
 			}
 			prevDate := values[len(values)-1].Date
-			date, err = time.Parse(dateFormat,
-				fmt.Sprintf("%s.%d", op.Date, prevDate.Year()))
+			date, err = nextDate(op.Date, prevDate)
 			if err != nil {
-				return nil, err
-			}
-			if prevDate.After(date) {
-				// Year transition
-				date, err = time.Parse(dateFormat,
-					fmt.Sprintf("%s.%d", op.Date, prevDate.Year()+1))
-				if err != nil {
-					return nil, err
-				}
+				return nil, fmt.Errorf("invalid date %q in operation %q: %w",
+					op.Date, op.Source, err)
 			}
 		}
+		orig := op.OriginalValue
+		if op.Value < 0 {
+			orig = -orig
+		}
 		values = append(values, Value{
-			Date:   date,
-			Source: op.Source,
-			Value:  total,
+			Date:             date,
+			Source:           op.Source,
+			Value:            total,
+			Amount:           total - prevTotal,
+			Currency:         currency,
+			OriginalValue:    orig,
+			OriginalCurrency: op.OriginalCurrency,
+			Words:            op.Words,
+			IsTotal:          op.IsTotal,
 		})
+		prevTotal = total
 	}
 	return values, nil
 }
 
-func extractFileValues(files []string) ([]Value, error) {
-	failed := 0
-	fail := func(fn string, err error) {
-		fmt.Fprintf(os.Stderr, "error: %s: %s\n", fn, err)
-		failed += 1
+// truncateSource shortens s to at most width runes, marking the cut with a
+// trailing "~". A width lower or equal to zero disables truncation.
+func truncateSource(s string, width int) string {
+	if width <= 0 {
+		return s
 	}
-	allValues := []Value{}
-	for _, file := range files {
-		r, err := pdf.Open(file)
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	if width == 1 {
+		return "~"
+	}
+	return string(runes[:width-1]) + "~"
+}
+
+// sourceWidth returns the room left for the source column when printing
+// values in a terminal width columns wide, or zero if width is unknown.
+func sourceWidth(width int) int {
+	if width <= 0 {
+		width, _ = strconv.Atoi(os.Getenv("COLUMNS"))
+	}
+	if width <= 0 {
+		return 0
+	}
+	// Date, balance and delta columns
+	w := width - len("2006-01-02 -      0.00 /    0.00 - ")
+	if w < 1 {
+		w = 1
+	}
+	return w
+}
+
+// openError wraps errors returned when opening PDF files.
+func openError(err error) error {
+	if errors.Is(err, pdf.ErrInvalidPassword) {
+		return fmt.Errorf("%w: %s", ErrEncrypted, err)
+	}
+	return err
+}
+
+// openPDF opens a PDF file, or reads it from stdin if file is "-". Encrypted
+// documents are decrypted with the empty password, then password if set.
+func openPDF(file, password string) (*pdf.Reader, error) {
+	if file == "-" {
+		data, err := ioutil.ReadAll(os.Stdin)
 		if err != nil {
 			return nil, err
 		}
-		ops, err := extractPDFOps(r)
+		return newPDFReader(bytes.NewReader(data), int64(len(data)), password)
+	}
+	fp, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	st, err := fp.Stat()
+	if err != nil {
+		fp.Close()
+		return nil, err
+	}
+	return newPDFReader(fp, st.Size(), password)
+}
+
+// newPDFReader returns a reader on the PDF document of size bytes read from
+// f, closing f on error if it is an io.Closer.
+func newPDFReader(f io.ReaderAt, size int64, password string) (*pdf.Reader, error) {
+	tried := false
+	r, err := pdf.NewReaderEncrypted(f, size, func() string {
+		// Returning an empty string stops the attempts
+		if tried {
+			return ""
+		}
+		tried = true
+		return password
+	})
+	if err != nil {
+		if c, ok := f.(io.Closer); ok {
+			c.Close()
+		}
+		return nil, openError(err)
+	}
+	return r, nil
+}
+
+// namedReport is a PDF report and the name of the file it was read from.
+type namedReport struct {
+	Name   string
+	Reader *pdf.Reader
+}
+
+// isReportFile returns true if path names a PDF report, possibly compressed.
+func isReportFile(path string) bool {
+	path = strings.ToLower(path)
+	return strings.HasSuffix(path, ".pdf") || strings.HasSuffix(path, ".pdf.gz") ||
+		strings.HasSuffix(path, ".zip")
+}
+
+// openReports opens the PDF reports stored in file. Files ending with .gz are
+// decompressed, and every PDF of files ending with .zip is returned, named
+// after the archive and their path in it. Compressed reports are read in
+// memory.
+func openReports(file, password string) ([]namedReport, error) {
+	lower := strings.ToLower(file)
+	switch {
+	case strings.HasSuffix(lower, ".gz"):
+		fp, err := os.Open(file)
 		if err != nil {
-			fail(file, err)
+			return nil, err
+		}
+		defer fp.Close()
+		zr, err := gzip.NewReader(fp)
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(zr)
+		if err != nil {
+			return nil, err
+		}
+		r, err := newPDFReader(bytes.NewReader(data), int64(len(data)), password)
+		if err != nil {
+			return nil, err
+		}
+		return []namedReport{{file, r}}, nil
+	case strings.HasSuffix(lower, ".zip"):
+		zr, err := zip.OpenReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		reports := []namedReport{}
+		for _, zf := range zr.File {
+			if !strings.EqualFold(path.Ext(zf.Name), ".pdf") {
+				continue
+			}
+			name := file + ":" + zf.Name
+			rc, err := zf.Open()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			data, err := ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			r, err := newPDFReader(bytes.NewReader(data), int64(len(data)), password)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			reports = append(reports, namedReport{name, r})
+		}
+		return reports, nil
+	}
+	r, err := openPDF(file, password)
+	if err != nil {
+		return nil, err
+	}
+	return []namedReport{{file, r}}, nil
+}
+
+// extractReportValues returns the reconciled values of a PDF report.
+func extractReportValues(cfg *ParserConfig, r *pdf.Reader) ([]Value, error) {
+	ops, err := extractPDFOps(cfg, r)
+	if err != nil {
+		return nil, err
+	}
+	return convertOpsToValues(cfg, ops)
+}
+
+// extractReaderValues returns the reconciled values of a PDF report of size
+// bytes read from r. Encrypted reports are decrypted with the empty password,
+// then password if set.
+func extractReaderValues(cfg *ParserConfig, r io.ReaderAt, size int64,
+	password string) ([]Value, error) {
+	pr, err := newPDFReader(r, size, password)
+	if err != nil {
+		return nil, err
+	}
+	return extractReportValues(cfg, pr)
+}
+
+// valuesInRange returns values dated between from and to included. Zero
+// bounds are ignored.
+func valuesInRange(values []Value, from, to time.Time) []Value {
+	kept := []Value{}
+	for _, v := range values {
+		if !from.IsZero() && v.Date.Before(from) ||
+			!to.IsZero() && v.Date.After(to) {
 			continue
 		}
-		values, err := convertOpsToValues(ops)
+		kept = append(kept, v)
+	}
+	return kept
+}
+
+// overlapKey identifies a value listed by overlapping reports. Account
+// records are identified by their date and balance only, since their labels
+// differ between the closing and opening records of consecutive reports.
+func overlapKey(v Value) string {
+	source := v.Source
+	if v.IsTotal {
+		source = ""
+	}
+	return fmt.Sprintf("%s-%t-%s-%d", v.Date.Format("2006-01-02"), v.IsTotal,
+		source, v.Value)
+}
+
+// trimOverlap removes from next the values already listed by prev, when
+// consecutive reports overlap. Values of next dated on or before the closing
+// date of prev are dropped if prev lists them, identified by their date,
+// source and value, as many times as prev does.
+func trimOverlap(prev, next []Value) []Value {
+	if len(prev) == 0 {
+		return next
+	}
+	closing := prev[len(prev)-1]
+	listed := map[string]int{}
+	for _, v := range prev {
+		listed[overlapKey(v)]++
+	}
+	kept := []Value{}
+	for _, v := range next {
+		if !v.Date.After(closing.Date) {
+			k := overlapKey(v)
+			if listed[k] > 0 {
+				listed[k]--
+				continue
+			}
+		}
+		kept = append(kept, v)
+	}
+	return kept
+}
+
+// extractFileValues parses and reconciles the reports of files, then prints
+// their values. Unless cfg.KeepGoing is set, the first file which cannot be
+// opened or any failed report aborts it. Otherwise values of successful
+// reports are returned along with a FailedReportsError.
+func extractFileValues(cfg *ParserConfig, files []string, password string,
+	width int) ([]Value, error) {
+	failed := 0
+	fail := func(fn string, err error) {
+		fmt.Fprintf(os.Stderr, "error: %s: %s\n", fn, err)
+		failed += 1
+	}
+	srcWidth := sourceWidth(width)
+	reports := [][]Value{}
+	allValues := []Value{}
+	for _, file := range files {
+		opened, err := openReports(file, password)
 		if err != nil {
+			if !cfg.KeepGoing {
+				return nil, &OpenError{File: file, Err: err}
+			}
 			fail(file, err)
 			continue
 		}
-		prev := int64(-1)
-		for _, v := range values {
-			d := v.Date.Format("2006-01-02")
-			h := v.Value / 100
-			l := v.Value % 100
-			dh := 0
-			dl := 0
-			if prev >= 0 {
-				delta := int(v.Value - prev)
-				if delta > 0 {
-					dh = delta / 100
-					dl = delta % 100
-				} else {
-					dh = -((-delta) / 100)
-					dl = (-delta) % 100
-				}
+		for _, report := range opened {
+			cfg.logf("%s: %d pages", report.Name, report.Reader.NumPage())
+			values, err := extractReportValues(cfg, report.Reader)
+			if err != nil {
+				fail(report.Name, err)
+				continue
 			}
-			prev = v.Value
-			fmt.Printf("%s - %6d.%02d / %4d.%02d - %s\n", d, h, l, dh, dl, v.Source)
+			cfg.logf("%s: %d values", report.Name, len(values))
+			reports = append(reports, values)
+		}
+	}
+	if failed > 0 && !cfg.KeepGoing {
+		return nil, &FailedReportsError{Count: failed}
+	}
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i][0].Date.Before(reports[j][0].Date)
+	})
+	for i, values := range reports {
+		if i > 0 {
+			values = trimOverlap(reports[i-1], values)
 		}
 		allValues = append(allValues, values...)
 	}
+	// Files or pages may not be given in chronological order
+	sortValues(allValues)
+	allValues = valuesInRange(allValues, cfg.From, cfg.To)
+	unit := int64(math.Pow10(cfg.CurrencyScale))
+	prev := int64(-1)
+	for _, v := range allValues {
+		d := v.Date.Format("2006-01-02")
+		h := v.Value / unit
+		l := v.Value % unit
+		dh := int64(0)
+		dl := int64(0)
+		if prev >= 0 {
+			delta := v.Value - prev
+			if delta > 0 {
+				dh = delta / unit
+				dl = delta % unit
+			} else {
+				dh = -((-delta) / unit)
+				dl = (-delta) % unit
+			}
+		}
+		prev = v.Value
+		fmt.Printf("%s - %6d.%0*d / %4d.%0*d - %s\n", d, h, cfg.CurrencyScale, l,
+			dh, cfg.CurrencyScale, dl, truncateSource(v.Source, srcWidth))
+	}
 	if failed > 0 {
-		return nil, fmt.Errorf("%d reports failed\n", failed)
+		return allValues, &FailedReportsError{Count: failed}
 	}
 	return allValues, nil
 }
 
-func writeJsonValues(values []Value, path string) error {
+// checkFiles parses and reconciles every file, without printing values. It
+// returns an error listing the files which failed.
+func checkFiles(cfg *ParserConfig, files []string, password string) error {
+	failed := []string{}
+	count := 0
+	for _, file := range files {
+		reports, err := openReports(file, password)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s: %s\n", file, err)
+			failed = append(failed, file)
+			count++
+			continue
+		}
+		for _, report := range reports {
+			count++
+			_, err = extractReportValues(cfg, report.Reader)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %s: %s\n", report.Name, err)
+				failed = append(failed, report.Name)
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d reports failed: %s", len(failed), count,
+			strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// ValueWriter serializes values to w.
+type ValueWriter func(w io.Writer, values []Value) error
+
+// jsonWriter returns a ValueWriter encoding values as a JSON array, indented
+// if pretty is true. HTML characters are left unescaped to keep sources
+// readable.
+func jsonWriter(pretty bool) ValueWriter {
+	return func(w io.Writer, values []Value) error {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		if pretty {
+			enc.SetIndent("", "  ")
+		}
+		return enc.Encode(values)
+	}
+}
+
+// writeNdjson encodes values as newline-delimited JSON, one object per line.
+func writeNdjson(w io.Writer, values []Value) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, v := range values {
+		err := enc.Encode(&v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EuroValue is a Value whose amounts are encoded in JSON as decimal numbers
+// of currency units, like 1234.56, instead of minor units. Scale is the
+// number of decimal digits of amounts.
+type EuroValue struct {
+	Value
+	Scale int
+}
+
+func (v EuroValue) MarshalJSON() ([]byte, error) {
+	type value Value
+	ev := struct {
+		value
+		Value         json.Number
+		Amount        json.Number
+		OriginalValue json.Number `json:",omitempty"`
+	}{
+		value:  value(v.Value),
+		Value:  json.Number(formatAmount(v.Value.Value, ".", v.Scale)),
+		Amount: json.Number(formatAmount(v.Amount, ".", v.Scale)),
+	}
+	if v.OriginalValue != 0 {
+		ev.OriginalValue = json.Number(formatAmount(v.OriginalValue, ".", v.Scale))
+	}
+	return json.Marshal(&ev)
+}
+
+func euroValues(values []Value, scale int) []EuroValue {
+	euros := make([]EuroValue, len(values))
+	for i, v := range values {
+		euros[i] = EuroValue{v, scale}
+	}
+	return euros
+}
+
+// euroJsonWriter is like jsonWriter but encodes values as EuroValue with
+// scale decimal digits.
+func euroJsonWriter(pretty bool, scale int) ValueWriter {
+	return func(w io.Writer, values []Value) error {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		if pretty {
+			enc.SetIndent("", "  ")
+		}
+		return enc.Encode(euroValues(values, scale))
+	}
+}
+
+// euroNdjsonWriter is like writeNdjson but encodes values as EuroValue with
+// scale decimal digits.
+func euroNdjsonWriter(scale int) ValueWriter {
+	return func(w io.Writer, values []Value) error {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		for _, v := range euroValues(values, scale) {
+			err := enc.Encode(v)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// writeValues serializes values with write into path, or stdout if path is
+// empty or "-".
+func writeValues(values []Value, path string, write ValueWriter) error {
+	if path == "" || path == "-" {
+		return write(os.Stdout, values)
+	}
 	fp, err := os.Create(path)
 	if err != nil {
 		return err
 	}
-	err = json.NewEncoder(fp).Encode(values)
+	err = write(fp, values)
 	err2 := fp.Close()
 	if err != nil {
 		return err
@@ -630,25 +1887,372 @@ func writeJsonValues(values []Value, path string) error {
 	return err2
 }
 
+// sortValues sorts values by date. Values with equal dates keep their
+// order.
+func sortValues(values []Value) {
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].Date.Before(values[j].Date)
+	})
+}
+
+// expandFiles replaces directories and glob patterns in args with the PDF
+// files, possibly compressed, they contain, sorted by name. Subdirectories
+// are scanned only if recursive is true. Other arguments are returned
+// unchanged.
+func expandFiles(args []string, recursive bool) ([]string, error) {
+	files := []string{}
+	for _, arg := range args {
+		paths := []string{arg}
+		if arg != "-" && strings.ContainsAny(arg, "*?[") {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", arg, err)
+			}
+			sort.Strings(matches)
+			paths = matches
+		}
+		for _, path := range paths {
+			st, err := os.Stat(path)
+			if err != nil || !st.IsDir() {
+				// Let missing files fail when opened
+				files = append(files, path)
+				continue
+			}
+			found := []string{}
+			err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() {
+					if p != path && !recursive {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if isReportFile(p) {
+					found = append(found, p)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			sort.Strings(found)
+			files = append(files, found...)
+		}
+	}
+	return files, nil
+}
+
+// newerFiles returns the files modified after path, or all of them if path
+// does not exist.
+func newerFiles(files []string, path string) ([]string, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return files, nil
+		}
+		return nil, err
+	}
+	newer := []string{}
+	for _, file := range files {
+		if file == "-" {
+			newer = append(newer, file)
+			continue
+		}
+		fst, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		if fst.ModTime().After(st.ModTime()) {
+			newer = append(newer, file)
+		}
+	}
+	return newer, nil
+}
+
+// mergeValues concatenates values sequences and sorts the result by date.
+// Values already listed by previous sequences are dropped, as many times as
+// they are listed, so identical values of a single sequence are kept. Values
+// with equal dates keep their input order.
+func mergeValues(seqs ...[]Value) []Value {
+	type key struct {
+		Date    int64
+		Source  string
+		Value   int64
+		Account string
+	}
+	merged := []Value{}
+	for _, values := range seqs {
+		listed := map[key]int{}
+		for _, v := range merged {
+			listed[key{v.Date.Unix(), v.Source, v.Value, v.Account}]++
+		}
+		for _, v := range values {
+			k := key{v.Date.Unix(), v.Source, v.Value, v.Account}
+			if listed[k] > 0 {
+				listed[k]--
+				continue
+			}
+			merged = append(merged, v)
+		}
+	}
+	sortValues(merged)
+	return merged
+}
+
+var (
+	reUnsafeName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// accountFileNames returns a distinct "values-<account>.json" file name for
+// each account, with unsafe characters replaced by underscores.
+func accountFileNames(accounts []string) map[string]string {
+	sorted := append([]string{}, accounts...)
+	sort.Strings(sorted)
+	names := map[string]string{}
+	used := map[string]bool{}
+	for _, account := range sorted {
+		base := reUnsafeName.ReplaceAllString(account, "_")
+		base = strings.Trim(base, ".")
+		if base == "" {
+			base = "default"
+		}
+		name := "values-" + base + ".json"
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("values-%s-%d.json", base, i)
+		}
+		used[name] = true
+		names[account] = name
+	}
+	return names
+}
+
+// writeAccountValues writes each account values to its own file in dir.
+// Parsed values all belong to the --account one, so there are several
+// accounts only when values are merged with --append or --incremental into
+// a file listing other accounts.
+func writeAccountValues(values []Value, dir string, write ValueWriter) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	accounts, byAccount := splitAccounts(values)
+	names := accountFileNames(accounts)
+	for _, account := range accounts {
+		path := filepath.Join(dir, names[account])
+		err := writeValues(byAccount[account], path, write)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 var (
 	parseCmd   = app.Command("parse", "parse BNP Paribas PDF reports")
-	parseFiles = parseCmd.Arg("files", "PDF files to parse").Strings()
-	parseJson  = parseCmd.Flag("json", "path to JSON output file").String()
+	parseFiles = parseCmd.Arg("files",
+		"PDF files, gzip or zip archives, directories or glob patterns to parse, - reads stdin").Strings()
+	parseJson   = parseCmd.Flag("json", "path to JSON output file").String()
+	parseNdjson = parseCmd.Flag("ndjson",
+		"write JSON output as newline-delimited values").Bool()
+	parsePretty = parseCmd.Flag("pretty", "indent JSON output").Bool()
+	parseRaw    = parseCmd.Flag("raw",
+		"include the words and columns of operations sources in JSON output").Bool()
+	parseAccount = parseCmd.Flag("account",
+		"account name attached to parsed values").String()
+	parseCategories = parseCmd.Flag("categories",
+		"path to rules file assigning categories to parsed values").String()
+	parsePassword = parseCmd.Flag("password",
+		"password of encrypted PDF files, the empty password is always tried").
+		String()
+	parseSignColumn = parseCmd.Flag("sign-column",
+		"column left of which amounts are debits").Default("500").Float64()
+	parseSignBands = parseCmd.Flag("sign-bands",
+		"infer debits and credits columns from amounts positions on each page").
+		Bool()
+	parseSeparator = parseCmd.Flag("separator",
+		"string inserted between words of operations sources").Default(" ").String()
+	parseRecursive = parseCmd.Flag("recursive",
+		"look for PDF files in subdirectories of directory arguments").Bool()
+	parseAppend = parseCmd.Flag("append",
+		"merge parsed values into the existing JSON output").Bool()
+	parseCheck = parseCmd.Flag("check",
+		"only check files can be parsed and reconciled, write nothing").Bool()
+	parseStatement = parseCmd.Flag("statement",
+		"statement type, livret statements interest lines are always credits").
+		Default(AutoStatement).Enum(AutoStatement, CheckingStatement, LivretStatement)
+	parseLocale = parseCmd.Flag("locale", "statements language, fr or en").
+			Default("fr").Enum("fr", "en")
+	parseDecimalSep = parseCmd.Flag("decimal-sep",
+		"separator between amounts units and minor units").Default(",").String()
+	parseGroupingSep = parseCmd.Flag("grouping-sep",
+		"separator between amounts thousands groups").Default(".").String()
+	parseColumnTolerance = parseCmd.Flag("column-tolerance",
+		"accepted distance between operations sources columns").Default("0").Float64()
+	parseFrom = parseCmd.Flag("from",
+		"only output values dated on or after this day, like 31.12.2006").String()
+	parseTo = parseCmd.Flag("to",
+		"only output values dated on or before this day, like 31.12.2006").String()
+	parseRowTolerance = parseCmd.Flag("row-tolerance",
+		"accepted vertical distance between words of the same line").
+		Default("0.5").Float64()
+	parseCurrencyScale = parseCmd.Flag("currency-scale",
+		"number of decimal digits of amounts").Default("2").Int()
+	parseTolerance = parseCmd.Flag("tolerance",
+		"accepted difference in minor units, like cents, between account records "+
+			"and operations").
+		Default("0").Int64()
+	parseIncremental = parseCmd.Flag("incremental",
+		"only parse files newer than the JSON output and merge them into it").Bool()
+	parseSplitDir = parseCmd.Flag("split-by-account",
+		"write each account values to values-<account>.json in this directory, "+
+			"parsed values belong to --account so this is only useful with "+
+			"--append or --incremental").
+		String()
+	parseVerbose = parseCmd.Flag("verbose",
+		"log parsing progress on stderr").Short('v').Bool()
+	parseMaxPages = parseCmd.Flag("max-pages",
+		"only process the first pages of each report").Int()
+	parseWidth = parseCmd.Flag("width",
+		"truncate printed sources to fit this terminal width, defaults to $COLUMNS").Int()
+	parseEuros = parseCmd.Flag("euros",
+		"write JSON amounts in currency units instead of minor units, such output "+
+			"cannot be merged into").Bool()
+	parseKeepGoing = parseCmd.Flag("keep-going",
+		"output values of successful reports when others fail, and succeed").Bool()
+	parseStrict = parseCmd.Flag("strict",
+		"with --keep-going, still fail after output if any report failed").Bool()
+	parseDedupReferences = parseCmd.Flag("dedup-references",
+		"consider operations sharing a bank reference identical").Bool()
 )
 
 func parseFn() error {
 	if len(*parseFiles) < 1 {
 		return fmt.Errorf("no PDF file specified")
 	}
-	values, err := extractFileValues(*parseFiles)
+	cfg := NewParserConfig()
+	cfg.WordSeparator = *parseSeparator
+	cfg.SignColumn = *parseSignColumn
+	cfg.SignBands = *parseSignBands
+	cfg.Tolerance = *parseTolerance
+	cfg.ColumnTolerance = *parseColumnTolerance
+	cfg.RowTolerance = *parseRowTolerance
+	cfg.Statement = *parseStatement
+	cfg.Verbose = *parseVerbose
+	cfg.CurrencyScale = *parseCurrencyScale
+	cfg.MaxPages = *parseMaxPages
+	cfg.KeepGoing = *parseKeepGoing
+	cfg.DedupReferences = *parseDedupReferences
+	if cfg.CurrencyScale < 1 {
+		return fmt.Errorf("currency scale must be positive")
+	}
+	if *parseEuros && (*parseAppend || *parseIncremental) {
+		return fmt.Errorf("--euros output cannot be merged with --append or --incremental")
+	}
+	var err error
+	if *parseFrom != "" {
+		cfg.From, err = parseDay(*parseFrom)
+		if err != nil {
+			return fmt.Errorf("invalid --from date: %w", err)
+		}
+	}
+	if *parseTo != "" {
+		cfg.To, err = parseDay(*parseTo)
+		if err != nil {
+			return fmt.Errorf("invalid --to date: %w", err)
+		}
+	}
+	cfg.Locale = locales[*parseLocale]
+	cfg.DecimalSep = *parseDecimalSep
+	cfg.GroupingSep = *parseGroupingSep
+	if cfg.DecimalSep == cfg.GroupingSep {
+		return fmt.Errorf("decimal and grouping separators must differ")
+	}
+	files, err := expandFiles(*parseFiles, *parseRecursive)
 	if err != nil {
 		return err
 	}
+	if len(files) == 0 {
+		return fmt.Errorf("no PDF file found")
+	}
+	if *parseCheck {
+		return checkFiles(cfg, files, *parsePassword)
+	}
+	previous := []Value{}
+	if *parseIncremental {
+		if *parseJson == "" {
+			return fmt.Errorf("--incremental requires --json")
+		}
+		newer, err := newerFiles(files, *parseJson)
+		if err != nil {
+			return err
+		}
+		if len(newer) < len(files) {
+			previous, err = readJsonValues(*parseJson)
+			if err != nil {
+				return err
+			}
+		}
+		files = newer
+	}
+	if *parseAppend {
+		if *parseJson == "" {
+			return fmt.Errorf("--append requires --json")
+		}
+		previous, err = readJsonValues(*parseJson)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	values, err := extractFileValues(cfg, files, *parsePassword, *parseWidth)
+	var failures *FailedReportsError
+	if err != nil && !(cfg.KeepGoing && errors.As(err, &failures)) {
+		return err
+	}
+	var categorize Renamer
+	if *parseCategories != "" {
+		categorize, err = readCategoryFile(*parseCategories)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range values {
+		if !*parseRaw {
+			values[i].Words = nil
+		}
+		values[i].Account = *parseAccount
+		if categorize != nil {
+			values[i].Category = categorize(values[i].Source)
+		}
+	}
+	if *parseIncremental || *parseAppend {
+		values = mergeValues(previous, values)
+	}
+	write := jsonWriter(*parsePretty)
+	if *parseEuros {
+		write = euroJsonWriter(*parsePretty, cfg.CurrencyScale)
+	}
+	if *parseNdjson {
+		write = writeNdjson
+		if *parseEuros {
+			write = euroNdjsonWriter(cfg.CurrencyScale)
+		}
+	}
 	if *parseJson != "" {
-		err = writeJsonValues(values, *parseJson)
+		err = writeValues(values, *parseJson, write)
 		if err != nil {
 			return err
 		}
 	}
+	if *parseSplitDir != "" {
+		err = writeAccountValues(values, *parseSplitDir, write)
+		if err != nil {
+			return err
+		}
+	}
+	if failures != nil && *parseStrict {
+		return failures
+	}
 	return nil
 }
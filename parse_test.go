@@ -0,0 +1,475 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pmezard/pdf"
+)
+
+// readStreamLines extracts the lines of the content stream fixture name.
+func readStreamLines(t *testing.T, cfg *ParserConfig, name string) []Line {
+	t.Helper()
+	fp, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+	lines, err := extractStreamLines(cfg, fp, pdf.Value{})
+	if err != nil {
+		t.Fatalf("could not extract %s: %s", name, err)
+	}
+	return lines
+}
+
+// openTestPDF opens the PDF fixture name, decrypted with password.
+func openTestPDF(t *testing.T, name, password string) *pdf.Reader {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := newPDFReader(bytes.NewReader(data), int64(len(data)), password)
+	if err != nil {
+		t.Fatalf("could not open %s: %s", name, err)
+	}
+	return r
+}
+
+// opSummaries returns the date, source and value of ops.
+func opSummaries(ops []*Op) []string {
+	values := []string{}
+	for _, op := range ops {
+		values = append(values, fmt.Sprintf("%s %s %d", op.Date, op.Source, op.Value))
+	}
+	return values
+}
+
+// testDay returns the day d of March 2020.
+func testDay(d int) time.Time {
+	return time.Date(2020, 3, d, 0, 0, 0, 0, time.UTC)
+}
+
+// testValues returns an account record followed by a debit, a categorized
+// credit and a categorized debit.
+func testValues() []Value {
+	return []Value{
+		{Date: testDay(1), Source: "SOLDE", Value: 100000, IsTotal: true},
+		{Date: testDay(2), Source: "CB \"SHOP\", A", Value: 98766, Amount: -1234},
+		{Date: testDay(15), Source: "VIR SALAIRE", Value: 298766, Amount: 200000,
+			Category: "salary"},
+		{Date: testDay(20), Source: "CB SHOP", Value: 298266, Amount: -500,
+			Category: "shopping"},
+	}
+}
+
+// lineValues returns the text of lines.
+func lineValues(lines []Line) []string {
+	values := []string{}
+	for _, l := range lines {
+		values = append(values, l.Value)
+	}
+	return values
+}
+
+func TestExtractStreamLinesStrings(t *testing.T) {
+	tests := []struct {
+		fixture string
+		lines   []string
+	}{
+		{"escapes.stream", []string{
+			`12.03 PRLV SEPA (ECHEANCE) REF(42) C:\note`,
+			`VIR (SALAIRE)`,
+		}},
+		{"hex.stream", []string{
+			`12.03 VIR SEPA`,
+			`SOLDE <41>`,
+		}},
+	}
+	for _, test := range tests {
+		lines := readStreamLines(t, NewParserConfig(), test.fixture)
+		got := lineValues(lines)
+		if !reflect.DeepEqual(got, test.lines) {
+			t.Errorf("%s: unexpected lines:\n%s\n!=\n%s", test.fixture,
+				strings.Join(got, "\n"), strings.Join(test.lines, "\n"))
+		}
+	}
+}
+
+func TestTokenizeError(t *testing.T) {
+	// Tokenizer errors used to dump the stream in the working directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	tests := []struct {
+		stream string
+		err    string
+	}{
+		{"BT <4g> Tj ET", `stream starting with "BT <4g> Tj ET"`},
+		{"BT <4g>" + strings.Repeat(" ", 2*maxErrorPrefix),
+			fmt.Sprintf("stream starting with %q", "BT <4g>"+
+				strings.Repeat(" ", maxErrorPrefix-7))},
+	}
+	for _, test := range tests {
+		err := tokenize(strings.NewReader(test.stream),
+			func(string, []interface{}) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: expected %s error, got %v", test.stream, test.err, err)
+		}
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		t.Errorf("unexpected file written: %s", e.Name())
+	}
+}
+
+func TestExtractStreamLinesQuoteErrors(t *testing.T) {
+	tests := []struct {
+		stream string
+		err    string
+	}{
+		{"BT ' ET", "missing ' operand"},
+		{"BT 1 2 \" ET", "invalid \" operand: 2"},
+	}
+	for _, test := range tests {
+		_, err := extractStreamLines(NewParserConfig(),
+			strings.NewReader(test.stream), pdf.Value{})
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: expected %q error, got %v", test.stream, test.err, err)
+		}
+	}
+}
+
+func TestParseOpsHexStrings(t *testing.T) {
+	lines := readStreamLines(t, NewParserConfig(), "hexbalance.stream")
+	ops, err := parseOps(NewParserConfig(), lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"01.03.2020 SOLDE CREDITEUR AU 01.03.2020 100000",
+		"05.03 PRLV (SEPA) -3000",
+		"31.03.2020 SOLDE CREDITEUR AU 31.03.2020 97000",
+	}
+	got := opSummaries(ops)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected operations:\n%s\n!=\n%s", strings.Join(got, "\n"),
+			strings.Join(want, "\n"))
+	}
+}
+
+func TestExtractPDFOpsReferences(t *testing.T) {
+	tests := []struct {
+		dedup bool
+		ops   []string
+	}{
+		{false, []string{
+			"01.03.2020 SOLDE CREDITEUR AU 01.03.2020 100000",
+			"05.03 PRLV SEPA ASSURANCE -3000",
+			"12.03 PRLV SEPA ASSURANCE -4500",
+			"31.03.2020 SOLDE CREDITEUR AU 31.03.2020 92500",
+		}},
+		{true, []string{
+			"01.03.2020 SOLDE CREDITEUR AU 01.03.2020 100000",
+			"05.03 PRLV SEPA ASSURANCE -3000",
+			"31.03.2020 SOLDE CREDITEUR AU 31.03.2020 92500",
+		}},
+	}
+	for _, test := range tests {
+		cfg := NewParserConfig()
+		cfg.DedupReferences = test.dedup
+		ops, err := extractPDFOps(cfg, openTestPDF(t, "references.pdf", ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := opSummaries(ops)
+		if !reflect.DeepEqual(got, test.ops) {
+			t.Errorf("dedup=%v: unexpected operations:\n%s\n!=\n%s", test.dedup,
+				strings.Join(got, "\n"), strings.Join(test.ops, "\n"))
+		}
+	}
+}
+
+// splitWords returns the space separated words of s, in increasing columns.
+func splitWords(s string) []Word {
+	words := []Word{}
+	for i, w := range strings.Fields(s) {
+		words = append(words, Word{S: w, Column: float64(10 * i)})
+	}
+	return words
+}
+
+func TestCurrencyScale(t *testing.T) {
+	tests := []struct {
+		scale    int
+		line     string
+		value    int64
+		foreign  int64
+		currency string
+		euros    string
+	}{
+		{2, "CB SHOP 12 , 50 USD 1 . 234 , 56", 123456, 1250, "USD", `"Value":1234.56`},
+		{3, "CB SHOP 12 , 500 KWD 1 . 234 , 567", 1234567, 12500, "KWD", `"Value":1234.567`},
+		// Two decimal digits foreign amounts are not amounts with scale 3
+		{3, "CB SHOP 12 , 50 USD 1 . 234 , 567", 1234567, 0, "", `"Value":1234.567`},
+	}
+	for _, test := range tests {
+		cfg := NewParserConfig()
+		cfg.CurrencyScale = test.scale
+		words := splitWords(test.line)
+		n, value, ok := findValue(cfg, words)
+		if !ok || value != test.value {
+			t.Errorf("%q: unexpected value: %d != %d", test.line, value, test.value)
+			continue
+		}
+		foreign, currency := findForeignAmount(cfg, words[:len(words)-n])
+		if foreign != test.foreign || currency != test.currency {
+			t.Errorf("%q: unexpected foreign amount: %d %q != %d %q", test.line,
+				foreign, currency, test.foreign, test.currency)
+		}
+		data, err := json.Marshal(EuroValue{Value{Value: value}, test.scale})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), test.euros) {
+			t.Errorf("%q: %s does not contain %s", test.line, data, test.euros)
+		}
+	}
+}
+
+// wordColumns returns the text and column of lines words.
+func wordColumns(lines []Line) []string {
+	columns := []string{}
+	for _, l := range lines {
+		for _, w := range l.Words {
+			columns = append(columns, fmt.Sprintf("%s@%g", w.S, w.Column))
+		}
+	}
+	return columns
+}
+
+func TestExtractStreamLinesScaling(t *testing.T) {
+	tests := []struct {
+		fixture string
+		columns []string
+	}{
+		// Tz does not move the text matrix origin, only the displacement
+		// of TJ adjustments, and is restored by Q
+		{"unscaled.stream", []string{"A@80", "BC@90", "D@90", "E@90"}},
+		{"scaled.stream", []string{"A@80", "BC@100", "D@85", "E@100"}},
+	}
+	for _, test := range tests {
+		lines := readStreamLines(t, NewParserConfig(), test.fixture)
+		got := wordColumns(lines)
+		if !reflect.DeepEqual(got, test.columns) {
+			t.Errorf("%s: unexpected columns: %v != %v", test.fixture, got,
+				test.columns)
+		}
+	}
+}
+
+func TestExtractReaderValuesEncrypted(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "encrypted.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		password string
+		err      error
+		values   []string
+	}{
+		{"", ErrEncrypted, nil},
+		{"wrong", ErrEncrypted, nil},
+		{"secret", nil, []string{
+			"2020-03-01 SOLDE CREDITEUR AU 01.03.2020 100000",
+			"2020-03-05 PRLV SEPA ASSURANCE 97000",
+			"2020-03-09 VIR SEPA SALAIRE 217050",
+			"2020-03-31 SOLDE CREDITEUR AU 31.03.2020 217050",
+		}},
+	}
+	for _, test := range tests {
+		values, err := extractReaderValues(NewParserConfig(), bytes.NewReader(data),
+			int64(len(data)), test.password)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("password %q: expected %v, got %v", test.password, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("password %q: %s", test.password, err)
+		}
+		got := []string{}
+		for _, v := range values {
+			got = append(got, fmt.Sprintf("%s %s %d", v.Date.Format("2006-01-02"),
+				v.Source, v.Value))
+		}
+		if !reflect.DeepEqual(got, test.values) {
+			t.Errorf("password %q: unexpected values:\n%s\n!=\n%s", test.password,
+				strings.Join(got, "\n"), strings.Join(test.values, "\n"))
+		}
+	}
+}
+
+// valueSummaries returns the date, source and value of values.
+func valueSummaries(values []Value) []string {
+	summaries := []string{}
+	for _, v := range values {
+		summaries = append(summaries, fmt.Sprintf("%s %s %d",
+			v.Date.Format("2006-01-02"), v.Source, v.Value))
+	}
+	return summaries
+}
+
+func TestExtractReportValuesConcurrent(t *testing.T) {
+	want := []string{
+		"2020-01-01 SOLDE CREDITEUR AU 01.01.2020 100000",
+		"2020-01-02 CB SHOP A 99000",
+		"2020-01-03 CB SHOP B 97000",
+		"2020-01-04 VIR SEPA SALAIRE 297000",
+		"2020-01-05 PRLV SEPA EDF 292000",
+		"2020-01-06 CB SHOP A 291000",
+		"2020-01-07 CB SHOP C 290500",
+		"2020-01-07 CB SHOP C 290000",
+		"2020-01-08 CB SHOP D 280000",
+		"2020-01-31 SOLDE CREDITEUR AU 31.01.2020 280000",
+	}
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+	// Run sequentially, then with one worker per page
+	for _, procs := range []int{1, 3} {
+		runtime.GOMAXPROCS(procs)
+		values, err := extractReportValues(NewParserConfig(),
+			openTestPDF(t, "statement.pdf", ""))
+		if err != nil {
+			t.Fatalf("%d workers: %s", procs, err)
+		}
+		got := valueSummaries(values)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%d workers: unexpected values:\n%s\n!=\n%s", procs,
+				strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
+
+func TestTrimOverlap(t *testing.T) {
+	prev := []Value{
+		{Date: testDay(1), Source: "SOLDE AU 01.03.2020", Value: 1000, IsTotal: true},
+		{Date: testDay(5), Source: "CB A", Value: 900},
+		{Date: testDay(10), Source: "SOLDE AU 10.03.2020", Value: 900, IsTotal: true},
+	}
+	tests := []struct {
+		name string
+		next []Value
+		want []string
+	}{
+		{"disjoint", []Value{
+			{Date: testDay(11), Source: "SOLDE", Value: 900, IsTotal: true},
+			{Date: testDay(12), Source: "CB B", Value: 800},
+		}, []string{
+			"2020-03-11 SOLDE 900",
+			"2020-03-12 CB B 800",
+		}},
+		{"overlap", []Value{
+			{Date: testDay(5), Source: "CB A", Value: 900},
+			{Date: testDay(10), Source: "SOLDE CREDITEUR AU 10.03.2020", Value: 900,
+				IsTotal: true},
+			// Reaching the closing balance does not end the overlap
+			{Date: testDay(10), Source: "VIR X", Value: 1000},
+			{Date: testDay(10), Source: "CB Y", Value: 900},
+			{Date: testDay(12), Source: "CB Z", Value: 800},
+		}, []string{
+			"2020-03-10 VIR X 1000",
+			"2020-03-10 CB Y 900",
+			"2020-03-12 CB Z 800",
+		}},
+	}
+	for _, test := range tests {
+		got := valueSummaries(trimOverlap(prev, test.next))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: unexpected values:\n%s\n!=\n%s", test.name,
+				strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+	}
+}
+
+func TestMergeValues(t *testing.T) {
+	// The second "CB A" is a zero amount operation, identical to the first
+	old := []Value{
+		{Date: testDay(1), Source: "SOLDE", Value: 1000, IsTotal: true},
+		{Date: testDay(2), Source: "CB A", Value: 900},
+		{Date: testDay(2), Source: "CB A", Value: 900},
+	}
+	tests := []struct {
+		name string
+		seqs [][]Value
+		want []string
+	}{
+		{"single", [][]Value{old}, []string{
+			"2020-03-01 SOLDE 1000",
+			"2020-03-02 CB A 900",
+			"2020-03-02 CB A 900",
+		}},
+		{"reparsed", [][]Value{old, old}, []string{
+			"2020-03-01 SOLDE 1000",
+			"2020-03-02 CB A 900",
+			"2020-03-02 CB A 900",
+		}},
+		{"appended", [][]Value{old[:2], {
+			{Date: testDay(2), Source: "CB A", Value: 900},
+			{Date: testDay(2), Source: "CB A", Value: 900},
+			{Date: testDay(3), Source: "CB B", Value: 800},
+		}}, []string{
+			"2020-03-01 SOLDE 1000",
+			"2020-03-02 CB A 900",
+			"2020-03-02 CB A 900",
+			"2020-03-03 CB B 800",
+		}},
+	}
+	for _, test := range tests {
+		got := valueSummaries(mergeValues(test.seqs...))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: unexpected values:\n%s\n!=\n%s", test.name,
+				strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+	}
+}
+
+func TestExtractReportValuesFilters(t *testing.T) {
+	// The last page only paints an image and has no operation
+	values, err := extractReportValues(NewParserConfig(),
+		openTestPDF(t, "filters.pdf", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"2020-03-01 SOLDE CREDITEUR AU 01.03.2020 100000",
+		"2020-03-05 PRLV SEPA ASSURANCE 97000",
+		"2020-03-09 VIR SEPA SALAIRE 217050",
+		"2020-03-31 SOLDE CREDITEUR AU 31.03.2020 217050",
+	}
+	got := valueSummaries(values)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected values:\n%s\n!=\n%s", strings.Join(got, "\n"),
+			strings.Join(want, "\n"))
+	}
+}
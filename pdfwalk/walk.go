@@ -0,0 +1,126 @@
+// Package pdfwalk traverses PDF objects graphs and lists the filters of
+// their streams.
+package pdfwalk
+
+import (
+	"github.com/pmezard/pdf"
+)
+
+// Walk traverses a pdf.Value graph while avoiding cycles by tracking object
+// pointers. callback is invoked for each visited value, in pre-order. If the
+// callback returns an error, the traversal stops and the error is forwarded to
+// the caller.
+func Walk(root pdf.Value, callback func(v pdf.Value) error) error {
+	seen := map[uint32]struct{}{}
+	var walkNode func(pdf.Value, int) error
+	walkNode = func(v pdf.Value, depth int) error {
+		err := callback(v)
+		if err != nil {
+			return err
+		}
+		switch v.Kind() {
+		case pdf.Dict:
+			for _, k := range v.Keys() {
+				id := v.KeyId(k)
+				if _, ok := seen[id]; ok {
+					continue
+				}
+				seen[id] = struct{}{}
+				err := walkNode(v.Key(k), depth+1)
+				delete(seen, id)
+				if err != nil {
+					return err
+				}
+			}
+		case pdf.Array:
+			l := v.Len()
+			for i := 0; i < l; i++ {
+				id := v.IndexId(i)
+				if _, ok := seen[id]; ok {
+					continue
+				}
+				seen[id] = struct{}{}
+				err := walkNode(v.Index(i), depth+1)
+				delete(seen, id)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return walkNode(root, 0)
+}
+
+// Streams walks root like Walk and invokes callback for each stream, with
+// the filters to apply to decode it.
+func Streams(root pdf.Value, callback func(v pdf.Value, filters []Filter) error) error {
+	return Walk(root, func(v pdf.Value) error {
+		if v.Kind() != pdf.Stream {
+			return nil
+		}
+		return callback(v, StreamFilters(v))
+	})
+}
+
+// Filter is a stream filter name and its DecodeParms parameters.
+type Filter struct {
+	Name             string
+	Predictor        int
+	Colors           int
+	BitsPerComponent int
+	Columns          int
+	EarlyChange      int
+}
+
+// NewFilter returns the named Filter with parameters read from params
+// dictionary, or their default values.
+func NewFilter(name string, params pdf.Value) Filter {
+	f := Filter{
+		Name:             name,
+		Predictor:        1,
+		Colors:           1,
+		BitsPerComponent: 8,
+		Columns:          1,
+		EarlyChange:      1,
+	}
+	if params.Kind() != pdf.Dict {
+		return f
+	}
+	for k, p := range map[string]*int{
+		"Predictor":        &f.Predictor,
+		"Colors":           &f.Colors,
+		"BitsPerComponent": &f.BitsPerComponent,
+		"Columns":          &f.Columns,
+		"EarlyChange":      &f.EarlyChange,
+	} {
+		if v := params.Key(k); v.Kind() == pdf.Integer {
+			*p = int(v.Int64())
+		}
+	}
+	return f
+}
+
+// StreamFilters returns the filters of stream v, in application order.
+func StreamFilters(v pdf.Value) []Filter {
+	// The specification names it Filter, a single name or an array. Filters
+	// is only read when Filter is missing.
+	values := v.Key("Filter")
+	if values.Kind() == pdf.Null {
+		values = v.Key("Filters")
+	}
+	params := v.Key("DecodeParms")
+	filters := []Filter{}
+	if values.Kind() == pdf.Name {
+		return append(filters, NewFilter(values.Name(), params))
+	}
+	l := values.Len()
+	for i := 0; i < l; i++ {
+		p := params
+		if params.Kind() == pdf.Array {
+			p = params.Index(i)
+		}
+		filters = append(filters, NewFilter(values.Index(i).Name(), p))
+	}
+	return filters
+}
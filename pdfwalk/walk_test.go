@@ -0,0 +1,52 @@
+package pdfwalk
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pmezard/pdf"
+)
+
+func TestStreams(t *testing.T) {
+	r, err := pdf.Open(filepath.Join("..", "testdata", "filters.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := Filter{Predictor: 1, Colors: 1, BitsPerComponent: 8, Columns: 1,
+		EarlyChange: 1}
+	flate := defaults
+	flate.Name = "FlateDecode"
+	lzw := defaults
+	lzw.Name = "LZWDecode"
+	predicted := flate
+	predicted.Predictor = 12
+	predicted.Columns = 16
+	runLength := defaults
+	runLength.Name = "RunLengthDecode"
+	tests := []struct {
+		name    string
+		root    pdf.Value
+		filters [][]Filter
+	}{
+		{"stacked", r.Page(1).V.Key("Contents"), [][]Filter{{flate, lzw}}},
+		{"predictor", r.Page(2).V.Key("Contents"), [][]Filter{{predicted}}},
+		// Filter prevails over Filters
+		{"run-length", r.Page(3).V.Key("Contents"), [][]Filter{{runLength}}},
+		{"image", r.Page(3).Resources(), [][]Filter{{}}},
+	}
+	for _, test := range tests {
+		got := [][]Filter{}
+		err := Streams(test.root, func(v pdf.Value, filters []Filter) error {
+			got = append(got, filters)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.filters) {
+			t.Errorf("%s: unexpected filters: %+v != %+v", test.name, got,
+				test.filters)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"time"
+)
+
+const (
+	// uncategorized names the pivot column of values without category.
+	uncategorized = "uncategorized"
+)
+
+// CategoryPivot holds the net account changes of calendar months by category,
+// in minor units. Cells are indexed by month then category.
+type CategoryPivot struct {
+	Months     []time.Time
+	Categories []string
+	Cells      [][]int64
+}
+
+// pivotCategories aggregates values deltas by calendar month and category,
+// from the first to the last month of values. Categories are sorted
+// alphabetically.
+func pivotCategories(values []Value) CategoryPivot {
+	pivot := CategoryPivot{}
+	if len(values) == 0 {
+		return pivot
+	}
+	monthOf := func(d time.Time) time.Time {
+		return time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	columns := map[string]int{}
+	for _, v := range values {
+		category := v.Category
+		if category == "" {
+			category = uncategorized
+		}
+		if _, ok := columns[category]; !ok {
+			columns[category] = 0
+			pivot.Categories = append(pivot.Categories, category)
+		}
+	}
+	sort.Strings(pivot.Categories)
+	for i, c := range pivot.Categories {
+		columns[c] = i
+	}
+	first, last := monthOf(values[0].Date), monthOf(values[0].Date)
+	for _, v := range values {
+		m := monthOf(v.Date)
+		if m.Before(first) {
+			first = m
+		}
+		if m.After(last) {
+			last = m
+		}
+	}
+	rows := map[time.Time]int{}
+	for m := first; !m.After(last); m = m.AddDate(0, 1, 0) {
+		rows[m] = len(pivot.Months)
+		pivot.Months = append(pivot.Months, m)
+		pivot.Cells = append(pivot.Cells, make([]int64, len(pivot.Categories)))
+	}
+	for i, delta := range valueDeltas(values) {
+		v := values[i]
+		category := v.Category
+		if category == "" {
+			category = uncategorized
+		}
+		pivot.Cells[rows[monthOf(v.Date)]][columns[category]] += delta
+	}
+	return pivot
+}
+
+// pivotWriter returns a ValueWriter encoding the category pivot of values as
+// CSV, with a month column followed by one column per category. Amounts are
+// in currency units, with decimal separating their scale decimal digits.
+func pivotWriter(decimal string, scale int) ValueWriter {
+	return func(w io.Writer, values []Value) error {
+		pivot := pivotCategories(values)
+		cw := csv.NewWriter(w)
+		err := cw.Write(append([]string{"month"}, pivot.Categories...))
+		if err != nil {
+			return err
+		}
+		for i, m := range pivot.Months {
+			row := []string{m.Format("2006-01")}
+			for _, cell := range pivot.Cells[i] {
+				row = append(row, formatAmount(cell, decimal, scale))
+			}
+			err := cw.Write(row)
+			if err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+}
+
+var (
+	pivotCmd    = app.Command("pivot", "write monthly net changes by category as CSV")
+	pivotValues = pivotCmd.Arg("values", "JSON values to aggregate").Required().String()
+	pivotRules  = pivotCmd.Flag("categories",
+		"path to rules file assigning categories, instead of values ones").String()
+	pivotOutput  = pivotCmd.Flag("output", "path to CSV output file, stdout by default").String()
+	pivotDecimal = pivotCmd.Flag("decimal", "decimal separator of amounts").
+			Default(".").String()
+	pivotCurrencyScale = pivotCmd.Flag("currency-scale",
+		"number of decimal digits of amounts").Default("2").Int()
+)
+
+func pivotFn() error {
+	values, err := readJsonValues(*pivotValues)
+	if err != nil {
+		return err
+	}
+	if *pivotRules != "" {
+		categorize, err := readCategoryFile(*pivotRules)
+		if err != nil {
+			return err
+		}
+		for i := range values {
+			values[i].Category = categorize(values[i].Source)
+		}
+	}
+	return writeValues(values, *pivotOutput, pivotWriter(*pivotDecimal, *pivotCurrencyScale))
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestPivotWriter(t *testing.T) {
+	values := append(testValues(),
+		Value{Date: time.Date(2020, 5, 3, 0, 0, 0, 0, time.UTC), Source: "CB SHOP",
+			Value: 297266, Amount: -1000, Category: "shopping"})
+	tests := []struct {
+		decimal string
+		scale   int
+		want    string
+	}{
+		// April has no values but is listed
+		{".", 2, `month,salary,shopping,uncategorized
+2020-03,2000.00,-5.00,-12.34
+2020-04,0.00,0.00,0.00
+2020-05,0.00,-10.00,0.00
+`},
+		{",", 3, `month,salary,shopping,uncategorized
+2020-03,"200,000","-0,500","-1,234"
+2020-04,"0,000","0,000","0,000"
+2020-05,"0,000","-1,000","0,000"
+`},
+	}
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		err := pivotWriter(test.decimal, test.scale)(buf, values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%q %d: unexpected output:\n%s\n!=\n%s", test.decimal,
+				test.scale, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// qifWriter returns a ValueWriter encoding values account changes as QIF bank
+// transactions, dated with dateFormat time layout. Amounts have scale decimal
+// digits. Account records carrying no change are omitted.
+func qifWriter(dateFormat string, scale int) ValueWriter {
+	return func(w io.Writer, values []Value) error {
+		buf := &strings.Builder{}
+		buf.WriteString("!Type:Bank\n")
+		for i, delta := range valueDeltas(values) {
+			if delta == 0 {
+				continue
+			}
+			v := values[i]
+			fmt.Fprintf(buf, "D%s\n", v.Date.Format(dateFormat))
+			fmt.Fprintf(buf, "T%s\n", formatAmount(delta, ".", scale))
+			fmt.Fprintf(buf, "P%s\n", strings.Replace(v.Source, "\n", " ", -1))
+			buf.WriteString("^\n")
+		}
+		_, err := io.WriteString(w, buf.String())
+		return err
+	}
+}
+
+var (
+	qifCmd        = app.Command("qif", "convert JSON values to QIF")
+	qifValues     = qifCmd.Arg("values", "JSON values to convert").Required().String()
+	qifOutput     = qifCmd.Flag("output", "path to QIF output file, stdout by default").String()
+	qifDateFormat = qifCmd.Flag("date-format", "QIF dates layout, as a Go time layout").
+			Default("02/01/2006").String()
+	qifCurrencyScale = qifCmd.Flag("currency-scale",
+		"number of decimal digits of amounts").Default("2").Int()
+)
+
+func qifFn() error {
+	values, err := readJsonValues(*qifValues)
+	if err != nil {
+		return err
+	}
+	return writeValues(values, *qifOutput, qifWriter(*qifDateFormat, *qifCurrencyScale))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestQifWriter(t *testing.T) {
+	tests := []struct {
+		dateFormat string
+		scale      int
+		want       string
+	}{
+		{"02/01/2006", 2, `!Type:Bank
+D02/03/2020
+T-12.34
+PCB "SHOP", A
+^
+D15/03/2020
+T2000.00
+PVIR SALAIRE
+^
+D20/03/2020
+T-5.00
+PCB SHOP
+^
+`},
+		{"2006-01-02", 3, `!Type:Bank
+D2020-03-02
+T-1.234
+PCB "SHOP", A
+^
+D2020-03-15
+T200.000
+PVIR SALAIRE
+^
+D2020-03-20
+T-0.500
+PCB SHOP
+^
+`},
+	}
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		err := qifWriter(test.dateFormat, test.scale)(buf, testValues())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%q %d: unexpected output:\n%s\n!=\n%s", test.dateFormat,
+				test.scale, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SourceTotal is the sum of account changes sharing the same source.
+type SourceTotal struct {
+	Source string
+	Count  int
+	Total  int64
+}
+
+// MonthTotal is the net account change over a calendar month.
+type MonthTotal struct {
+	Month time.Time
+	Net   int64
+}
+
+// Summary aggregates account changes over a sequence of values, in minor
+// units.
+type Summary struct {
+	In      int64
+	Out     int64
+	Sources []SourceTotal
+	Months  []MonthTotal
+}
+
+// summarize computes values Summary. Sources are sorted by decreasing
+// absolute total, months chronologically.
+func summarize(values []Value) Summary {
+	sum := Summary{}
+	sources := map[string]*SourceTotal{}
+	months := map[time.Time]*MonthTotal{}
+	for i, delta := range valueDeltas(values) {
+		v := values[i]
+		if delta > 0 {
+			sum.In += delta
+		} else {
+			sum.Out += delta
+		}
+		st := sources[v.Source]
+		if st == nil {
+			st = &SourceTotal{Source: v.Source}
+			sources[v.Source] = st
+		}
+		st.Count++
+		st.Total += delta
+		month := time.Date(v.Date.Year(), v.Date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		mt := months[month]
+		if mt == nil {
+			mt = &MonthTotal{Month: month}
+			months[month] = mt
+		}
+		mt.Net += delta
+	}
+	for _, st := range sources {
+		sum.Sources = append(sum.Sources, *st)
+	}
+	sort.Slice(sum.Sources, func(i, j int) bool {
+		a, b := abs(sum.Sources[i].Total), abs(sum.Sources[j].Total)
+		if a != b {
+			return a > b
+		}
+		return sum.Sources[i].Source < sum.Sources[j].Source
+	})
+	for _, mt := range months {
+		sum.Months = append(sum.Months, *mt)
+	}
+	sort.Slice(sum.Months, func(i, j int) bool {
+		return sum.Months[i].Month.Before(sum.Months[j].Month)
+	})
+	return sum
+}
+
+var (
+	reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+		"amount": func(v int64, scale int) string {
+			return formatAmount(v, ".", scale)
+		},
+	}).Parse(`<div id="summary">
+<h2>Summary</h2>
+<table>
+<tr><td>Total in</td><td>{{amount .In $.Scale}} {{$.Currency}}</td></tr>
+<tr><td>Total out</td><td>{{amount .Out $.Scale}} {{$.Currency}}</td></tr>
+</table>
+<h2>Monthly net</h2>
+<table>
+{{range .Months}}<tr><td>{{.Month.Format "2006-01"}}</td><td>{{amount .Net $.Scale}} {{$.Currency}}</td></tr>
+{{end}}</table>
+<h2>Top sources</h2>
+<table>
+{{range .Sources}}<tr><td>{{.Source}}</td><td>{{.Count}}</td><td>{{amount .Total $.Scale}} {{$.Currency}}</td></tr>
+{{end}}</table>
+</div>
+`))
+
+	reScriptRef = regexp.MustCompile(`<script src="(scripts/[^"]+)"></script>`)
+	reStyleRef  = regexp.MustCompile(`<link [^>]*href="(scripts/[^"]+)">`)
+)
+
+// inlineAssets replaces references to scripts and stylesheets under scripts/
+// with their content in assets, so the page does not depend on external files.
+func inlineAssets(html []byte, assets fs.FS) ([]byte, error) {
+	var err error
+	inline := func(re *regexp.Regexp, tag string) {
+		html = re.ReplaceAllFunc(html, func(m []byte) []byte {
+			path := string(re.FindSubmatch(m)[1])
+			data, e := fs.ReadFile(assets, strings.TrimPrefix(path, "scripts/"))
+			if e != nil {
+				err = e
+				return m
+			}
+			return []byte("<" + tag + ">" + string(data) + "</" + tag + ">")
+		})
+	}
+	inline(reScriptRef, "script")
+	inline(reStyleRef, "style")
+	return html, err
+}
+
+// renderReport returns a self-contained HTML page charting values, followed
+// by a summary of the top sources and monthly account changes, with amounts
+// of scale decimal digits. Scripts referenced by html are inlined from assets.
+// Values must share the same currency.
+func renderReport(html []byte, assets fs.FS, values []Value, top,
+	scale int) ([]byte, error) {
+	html, err := embedJson(html, values)
+	if err != nil {
+		return nil, err
+	}
+	html, err = inlineAssets(html, assets)
+	if err != nil {
+		return nil, err
+	}
+	currency, err := valuesCurrency(values)
+	if err != nil {
+		return nil, err
+	}
+	sum := summarize(values)
+	if top >= 0 && len(sum.Sources) > top {
+		sum.Sources = sum.Sources[:top]
+	}
+	buf := &bytes.Buffer{}
+	err = reportTemplate.Execute(buf, &struct {
+		Summary
+		Scale    int
+		Currency string
+	}{sum, scale, currency})
+	if err != nil {
+		return nil, err
+	}
+	// Inlined scripts may contain "</body>" as well
+	i := bytes.LastIndex(html, []byte("</body>"))
+	if i < 0 {
+		return nil, fmt.Errorf("could not find </body> in report template")
+	}
+	return append(html[:i], append(buf.Bytes(), html[i:]...)...), nil
+}
+
+var (
+	reportCmd    = app.Command("report", "write a static HTML summary of JSON values")
+	reportValues = reportCmd.Arg("values", "JSON values to summarize").Required().String()
+	reportOut    = reportCmd.Flag("out", "path to HTML output file").
+			Default("report.html").String()
+	reportTop = reportCmd.Flag("top", "number of top sources to list").
+			Default("10").Int()
+	reportAssets = reportCmd.Flag("assets",
+		"inline main.html and scripts from this directory instead of embedded ones").
+		String()
+	reportTemplatePath = reportCmd.Flag("template",
+		"path to HTML page template with a $DATA$ placeholder, instead of main.html").
+		String()
+	reportCurrencyScale = reportCmd.Flag("currency-scale",
+		"number of decimal digits of amounts").Default("2").Int()
+)
+
+func reportFn() error {
+	values, err := readJsonValues(*reportValues)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("no values to report")
+	}
+	assets, err := openAssets(*reportAssets)
+	if err != nil {
+		return err
+	}
+	html, err := readTemplate(assets, *reportTemplatePath)
+	if err != nil {
+		return err
+	}
+	html, err = renderReport(html, assets, values, *reportTop, *reportCurrencyScale)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(*reportOut, html, 0644)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderReportEmbeddedAssets(t *testing.T) {
+	assets, err := openAssets("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	html, err := readTemplate(assets, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := []Value{
+		{Date: time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), Source: "SOLDE",
+			Value: 100000, IsTotal: true},
+		{Date: time.Date(2020, 3, 5, 0, 0, 0, 0, time.UTC), Source: "PRLV EDF",
+			Value: 97000, Amount: -3000},
+	}
+	got, err := renderReport(html, assets, values, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range [][]byte{[]byte(`src="scripts/`), []byte(`href="scripts/`)} {
+		if bytes.Contains(got, ref) {
+			t.Errorf("report still references %s", ref)
+		}
+	}
+	if !bytes.Contains(got, []byte("<td>PRLV EDF</td>")) {
+		t.Errorf("top sources missing from report")
+	}
+}
+
+func TestRenderReportCurrency(t *testing.T) {
+	assets, err := openAssets("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	html, err := readTemplate(assets, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		currencies []string
+		want       string
+		err        string
+	}{
+		{"default", []string{"", ""}, "<td>-30.00 EUR</td>", ""},
+		{"single", []string{"USD", "USD"}, "<td>-30.00 USD</td>", ""},
+		{"mixed", []string{"EUR", "USD"}, "", "cannot mix EUR and USD"},
+	}
+	for _, test := range tests {
+		values := []Value{
+			{Date: testDay(1), Source: "SOLDE", Value: 100000, IsTotal: true,
+				Currency: test.currencies[0]},
+			{Date: testDay(5), Source: "PRLV EDF", Value: 97000, Amount: -3000,
+				Currency: test.currencies[1]},
+		}
+		got, err := renderReport(html, assets, values, 10, 2)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected %q error, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if !bytes.Contains(got, []byte(test.want)) {
+			t.Errorf("%s: %q not found in report", test.name, test.want)
+		}
+	}
+}
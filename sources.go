@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+)
+
+var (
+	sourcesCmd = app.Command("sources",
+		"list distinct sources of JSON values with their count and total change")
+	sourcesValues        = sourcesCmd.Arg("values", "JSON values to list").Required().String()
+	sourcesCurrencyScale = sourcesCmd.Flag("currency-scale",
+		"number of decimal digits of amounts").Default("2").Int()
+)
+
+func sourcesFn() error {
+	values, err := readJsonValues(*sourcesValues)
+	if err != nil {
+		return err
+	}
+	for _, st := range summarize(values).Sources {
+		fmt.Printf("%12s %5d %s\n", formatAmount(st.Total, ".", *sourcesCurrencyScale), st.Count, st.Source)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Stats summarizes a sequence of values. Amounts are in minor units of
+// Currency and balances sum the balances of all accounts.
+type Stats struct {
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Count      int       `json:"count"`
+	Currency   string    `json:"currency"`
+	Credits    int64     `json:"credits"`
+	Debits     int64     `json:"debits"`
+	Net        int64     `json:"net"`
+	MinBalance int64     `json:"min_balance"`
+	MaxBalance int64     `json:"max_balance"`
+}
+
+// computeStats returns the Stats of values, which must be sorted by date and
+// share the same currency. Count is the number of operations, account records
+// are not counted.
+func computeStats(values []Value) (Stats, error) {
+	st := Stats{}
+	if len(values) == 0 {
+		return st, nil
+	}
+	currency, err := valuesCurrency(values)
+	if err != nil {
+		return st, err
+	}
+	st.Currency = currency
+	st.From = values[0].Date
+	st.To = values[len(values)-1].Date
+	last := map[string]int64{}
+	balance := int64(0)
+	for i, delta := range valueDeltas(values) {
+		v := values[i]
+		if !v.IsTotal {
+			st.Count++
+		}
+		if delta > 0 {
+			st.Credits += delta
+		} else {
+			st.Debits += delta
+		}
+		balance += v.Value - last[v.Account]
+		last[v.Account] = v.Value
+		if i == 0 || balance < st.MinBalance {
+			st.MinBalance = balance
+		}
+		if i == 0 || balance > st.MaxBalance {
+			st.MaxBalance = balance
+		}
+	}
+	st.Net = st.Credits + st.Debits
+	return st, nil
+}
+
+// sortStatsValues sorts values by date then account. Values with equal dates
+// and accounts keep their order.
+func sortStatsValues(values []Value) {
+	sort.SliceStable(values, func(i, j int) bool {
+		a, b := values[i], values[j]
+		if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		return a.Account < b.Account
+	})
+}
+
+var (
+	statsCmd           = app.Command("stats", "print summary statistics of JSON values")
+	statsValues        = statsCmd.Arg("values", "JSON values to summarize").Required().String()
+	statsJson          = statsCmd.Flag("json", "print statistics as JSON").Bool()
+	statsCurrencyScale = statsCmd.Flag("currency-scale",
+		"number of decimal digits of amounts").Default("2").Int()
+)
+
+func statsFn() error {
+	values, err := readJsonValues(*statsValues)
+	if err != nil {
+		return err
+	}
+	sortStatsValues(values)
+	st, err := computeStats(values)
+	if err != nil {
+		return err
+	}
+	if *statsJson {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(&st)
+	}
+	if st.Count == 0 {
+		fmt.Println("no operations")
+		return nil
+	}
+	amount := func(v int64) string {
+		return formatAmount(v, ".", *statsCurrencyScale)
+	}
+	fmt.Printf("period:      %s to %s\n", st.From.Format("2006-01-02"),
+		st.To.Format("2006-01-02"))
+	fmt.Printf("operations:  %d\n", st.Count)
+	fmt.Printf("credits:     %s %s\n", amount(st.Credits), st.Currency)
+	fmt.Printf("debits:      %s %s\n", amount(st.Debits), st.Currency)
+	fmt.Printf("net:         %s %s\n", amount(st.Net), st.Currency)
+	fmt.Printf("min balance: %s %s\n", amount(st.MinBalance), st.Currency)
+	fmt.Printf("max balance: %s %s\n", amount(st.MaxBalance), st.Currency)
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComputeStats(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []Value
+		want   Stats
+		err    string
+	}{
+		{"empty", nil, Stats{}, ""},
+		{"unsorted", []Value{
+			{Date: testDay(5), Source: "CB A", Value: 900, Account: "a"},
+			{Date: testDay(1), Source: "SOLDE", Value: 500, Account: "b", IsTotal: true},
+			{Date: testDay(1), Source: "SOLDE", Value: 1000, Account: "a", IsTotal: true},
+			{Date: testDay(8), Source: "VIR", Value: 700, Account: "b"},
+			{Date: testDay(31), Source: "SOLDE", Value: 700, Account: "b", IsTotal: true},
+			{Date: testDay(31), Source: "SOLDE", Value: 900, Account: "a", IsTotal: true},
+		}, Stats{
+			From:       testDay(1),
+			To:         testDay(31),
+			Count:      2,
+			Currency:   "EUR",
+			Credits:    200,
+			Debits:     -100,
+			Net:        100,
+			MinBalance: 1000,
+			MaxBalance: 1600,
+		}, ""},
+		{"currency", []Value{
+			{Date: testDay(1), Source: "SOLDE", Value: 1000, IsTotal: true,
+				Currency: "USD"},
+			{Date: testDay(5), Source: "CB A", Value: 900, Currency: "USD"},
+		}, Stats{
+			From:       testDay(1),
+			To:         testDay(5),
+			Count:      1,
+			Currency:   "USD",
+			Debits:     -100,
+			Net:        -100,
+			MinBalance: 900,
+			MaxBalance: 1000,
+		}, ""},
+		{"mixed currencies", []Value{
+			{Date: testDay(1), Source: "SOLDE", Value: 1000, IsTotal: true,
+				Currency: "EUR"},
+			{Date: testDay(5), Source: "CB A", Value: 900, Currency: "USD"},
+		}, Stats{}, "cannot mix EUR and USD"},
+	}
+	for _, test := range tests {
+		values := append([]Value{}, test.values...)
+		sortStatsValues(values)
+		got, err := computeStats(values)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected %q error, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: unexpected stats:\n%+v\n!=\n%+v", test.name, got, test.want)
+		}
+	}
+}
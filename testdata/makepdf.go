@@ -0,0 +1,465 @@
+//go:build ignore
+
+// makepdf writes the PDF fixtures used by tests. Run it from the repository
+// root with:
+//
+//	go run testdata/makepdf.go
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"crypto/rc4"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+type object struct {
+	Body   string
+	Data   []byte
+	Stream bool
+}
+
+// document is a minimal PDF writer. Objects are numbered from 1 in the order
+// they are added.
+type document struct {
+	objects []object
+}
+
+func (d *document) add(body string) int {
+	d.objects = append(d.objects, object{Body: body})
+	return len(d.objects)
+}
+
+func (d *document) addStream(dict string, data []byte) int {
+	d.objects = append(d.objects, object{Body: dict, Data: data, Stream: true})
+	return len(d.objects)
+}
+
+func (d *document) set(id int, body string) {
+	d.objects[id-1].Body = body
+}
+
+// page is a page content stream and its resources dictionary.
+type page struct {
+	Content   string
+	Resources string
+}
+
+// addPages adds a page tree holding pages and the document catalog, whose
+// object number is returned.
+func (d *document) addPages(pages []page) int {
+	parent := d.add("")
+	kids := []string{}
+	for _, p := range pages {
+		content := d.addStream("<<>>", []byte(p.Content))
+		resources := p.Resources
+		if resources == "" {
+			resources = "<<>>"
+		}
+		id := d.add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 595 842] "+
+			"/Resources %s /Contents %d 0 R >>", parent, resources, content))
+		kids = append(kids, fmt.Sprintf("%d 0 R", id))
+	}
+	d.set(parent, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>",
+		strings.Join(kids, " "), len(kids)))
+	return d.add(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", parent))
+}
+
+var (
+	passwordPad = []byte{
+		0x28, 0xbf, 0x4e, 0x5e, 0x4e, 0x75, 0x8a, 0x41, 0x64, 0x00, 0x4e, 0x56,
+		0xff, 0xfa, 0x01, 0x08, 0x2e, 0x2e, 0x00, 0xb6, 0xd0, 0x68, 0x3e, 0x80,
+		0x2f, 0x0c, 0xa9, 0xfe, 0x64, 0x53, 0x69, 0x7a,
+	}
+	fileID = []byte("bnp-test-fixture")
+)
+
+func pad(password string) []byte {
+	return append([]byte(password), passwordPad[:32-len(password)]...)
+}
+
+func rc4Crypt(key, data []byte) []byte {
+	c, err := rc4.NewCipher(key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	out := make([]byte, len(data))
+	c.XORKeyStream(out, data)
+	return out
+}
+
+// rc4Rounds applies the 20 RC4 passes of revision 3 security handlers.
+func rc4Rounds(key, data []byte) []byte {
+	data = rc4Crypt(key, data)
+	for i := 1; i <= 19; i++ {
+		k := make([]byte, len(key))
+		for j := range key {
+			k[j] = key[j] ^ byte(i)
+		}
+		data = rc4Crypt(k, data)
+	}
+	return data
+}
+
+// encryption returns the 128 bits RC4 file key of password, and the
+// Encrypt dictionary of the standard security handler, revision 3.
+func encryption(password string) ([]byte, string) {
+	perms := int32(-4)
+	// Owner and user passwords are the same
+	sum := md5.Sum(pad(password))
+	ownerKey := sum[:]
+	for i := 0; i < 50; i++ {
+		sum = md5.Sum(ownerKey)
+		ownerKey = sum[:]
+	}
+	o := rc4Rounds(ownerKey, pad(password))
+
+	h := md5.New()
+	h.Write(pad(password))
+	h.Write(o)
+	p := uint32(perms)
+	h.Write([]byte{byte(p), byte(p >> 8), byte(p >> 16), byte(p >> 24)})
+	h.Write(fileID)
+	key := h.Sum(nil)
+	for i := 0; i < 50; i++ {
+		sum = md5.Sum(key)
+		key = sum[:]
+	}
+
+	h.Reset()
+	h.Write(passwordPad)
+	h.Write(fileID)
+	u := rc4Rounds(key, h.Sum(nil))
+	u = append(u, make([]byte, 16)...)
+
+	dict := fmt.Sprintf("<< /Filter /Standard /V 2 /R 3 /Length 128 /P %d "+
+		"/O <%x> /U <%x> >>", perms, o, u)
+	return key, dict
+}
+
+// write writes the document into path, rooted at catalog. Streams are
+// encrypted with password if it is not empty.
+func (d *document) write(path string, catalog int, password string) {
+	var key []byte
+	encrypt := 0
+	if password != "" {
+		var dict string
+		key, dict = encryption(password)
+		encrypt = d.add(dict)
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteString("%PDF-1.4\n")
+	offsets := []int{}
+	for i, obj := range d.objects {
+		id := i + 1
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(buf, "%d 0 obj\n", id)
+		if !obj.Stream {
+			fmt.Fprintf(buf, "%s\nendobj\n", obj.Body)
+			continue
+		}
+		data := obj.Data
+		if key != nil {
+			h := md5.New()
+			h.Write(key)
+			h.Write([]byte{byte(id), byte(id >> 8), byte(id >> 16), 0, 0})
+			data = rc4Crypt(h.Sum(nil), data)
+		}
+		dict := strings.TrimSuffix(obj.Body, ">>")
+		fmt.Fprintf(buf, "%s /Length %d >>\nstream\n", dict, len(data))
+		buf.Write(data)
+		buf.WriteString("\nendstream\nendobj\n")
+	}
+	xref := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n0000000000 65535 f \n", len(d.objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n \n", off)
+	}
+	trailer := fmt.Sprintf("/Size %d /Root %d 0 R /ID [<%x> <%x>]",
+		len(d.objects)+1, catalog, fileID, fileID)
+	if encrypt != 0 {
+		trailer += fmt.Sprintf(" /Encrypt %d 0 R", encrypt)
+	}
+	fmt.Fprintf(buf, "trailer\n<< %s >>\nstartxref\n%d\n%%%%EOF\n", trailer, xref)
+	err := ioutil.WriteFile(filepath.Join("testdata", path), buf.Bytes(), 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func writePages(path string, pages []page, password string) {
+	d := &document{}
+	catalog := d.addPages(pages)
+	d.write(path, catalog, password)
+}
+
+// text shows s at x, y in its own text object.
+func text(x, y float64, s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`).Replace(s)
+	return fmt.Sprintf("BT 1 0 0 1 %g %g Tm (%s) Tj ET\n", x, y, s)
+}
+
+// Statement columns.
+const (
+	dateCol   = 40
+	sourceCol = 80
+	debitCol  = 420
+	creditCol = 520
+)
+
+// words shows each of parts in its own text object, from x by step.
+func words(x, step, y float64, parts ...string) string {
+	s := ""
+	for i, p := range parts {
+		s += text(x+float64(i)*step, y, p)
+	}
+	return s
+}
+
+// amount shows an amount like "1.234,56" at x, y, as separate digits groups
+// and separators words.
+func amount(x, y float64, s string) string {
+	parts := []string{}
+	start := 0
+	for i, c := range s {
+		if c == '.' || c == ',' {
+			parts = append(parts, s[start:i], string(c))
+			start = i + 1
+		}
+	}
+	parts = append(parts, s[start:])
+	return words(x, 8, y, parts...)
+}
+
+// opLine shows an operation line. date is like "05.03" and may be empty,
+// like debit and credit amounts.
+func opLine(y float64, date, debit, credit string, source ...string) string {
+	s := ""
+	if date != "" {
+		s += words(dateCol, 8, y, date[:2], ".", date[3:])
+	}
+	s += words(sourceCol, 60, y, source...)
+	if debit != "" {
+		s += amount(debitCol, y, debit)
+	}
+	if credit != "" {
+		s += amount(creditCol, y, credit)
+	}
+	return s
+}
+
+// totalLine shows a credit account record line dated like "01.03.2020".
+func totalLine(y float64, date, credit string) string {
+	return text(sourceCol, y, "SOLDE CREDITEUR AU "+date) + amount(creditCol, y, credit)
+}
+
+func main() {
+	writeToUnicode()
+	writeReferences()
+	writeEncrypted()
+	writeStatement()
+	writeFilters()
+}
+
+// writeToUnicode writes a page showing two-bytes glyph codes mapped by the
+// ToUnicode CMap of font F1, followed by text in font F2, which has none.
+// Both fonts are direct dictionaries.
+func writeToUnicode() {
+	d := &document{}
+	cmap := d.addStream("<<>>", []byte(`/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+1 begincodespacerange
+<0000> <FFFF>
+endcodespacerange
+3 beginbfchar
+<0033> <0053>
+<0032> <004F>
+<005C> <004C>
+endbfchar
+1 beginbfrange
+<0040> <0041> <0044>
+endbfrange
+1 beginbfrange
+<5C00> <5C01> [<0041> <0055>]
+endbfrange
+endcmap
+CMapName currentdict /CMap defineresource pop
+end
+end
+`))
+	resources := fmt.Sprintf("<< /Font << "+
+		"/F1 << /Type /Font /Subtype /Type0 /BaseFont /Subset /Encoding /Identity-H "+
+		"/ToUnicode %d 0 R >> "+
+		"/F2 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica >> >> >>", cmap)
+	content := "BT /F1 10 Tf 1 0 0 1 80 700 Tm <00330032005C00400041> Tj\n" +
+		"1 0 0 1 80 690 Tm <5C005C01> Tj\n" +
+		"/F2 10 Tf 1 0 0 1 80 680 Tm <0033> Tj ET\n"
+	catalog := d.addPages([]page{{Content: content, Resources: resources}})
+	d.write("tounicode.pdf", catalog, "")
+}
+
+// writeReferences writes a statement with two distinct operations sharing
+// the same bank reference, like monthly direct debits of a mandate.
+func writeReferences() {
+	content := totalLine(700, "01.03.2020", "1.000,00") +
+		opLine(690, "05.03", "30,00", "", "PRLV SEPA", "ASSURANCE", "1234567890") +
+		opLine(680, "12.03", "45,00", "", "PRLV SEPA", "ASSURANCE", "1234567890") +
+		totalLine(670, "31.03.2020", "925,00")
+	writePages("references.pdf", []page{{Content: content}}, "")
+}
+
+// writeEncrypted writes a statement encrypted with the "secret" password.
+func writeEncrypted() {
+	content := totalLine(700, "01.03.2020", "1.000,00") +
+		opLine(690, "05.03", "30,00", "", "PRLV SEPA", "ASSURANCE") +
+		opLine(680, "09.03", "", "1.200,50", "VIR SEPA", "SALAIRE") +
+		totalLine(670, "31.03.2020", "2.170,50")
+	writePages("encrypted.pdf", []page{{Content: content}}, "secret")
+}
+
+// writeStatement writes a three pages statement. The last operation of the
+// first page has its amount on the second page, which repeats an operation.
+func writeStatement() {
+	pages := []page{
+		{Content: totalLine(700, "01.01.2020", "1.000,00") +
+			opLine(690, "02.01", "10,00", "", "CB", "SHOP A") +
+			opLine(680, "03.01", "20,00", "", "CB", "SHOP B") +
+			opLine(670, "04.01", "", "2.000,00", "VIR SEPA", "SALAIRE") +
+			opLine(660, "05.01", "", "", "PRLV SEPA", "EDF")},
+		{Content: opLine(700, "", "50,00", "") +
+			opLine(690, "06.01", "10,00", "", "CB", "SHOP A") +
+			opLine(680, "07.01", "5,00", "", "CB", "SHOP C") +
+			opLine(670, "07.01", "5,00", "", "CB", "SHOP C")},
+		{Content: opLine(700, "08.01", "100,00", "", "CB", "SHOP D") +
+			totalLine(690, "31.01.2020", "2.800,00")},
+	}
+	writePages("statement.pdf", pages, "")
+}
+
+// deflate encodes data as FlateDecode zlib data.
+func deflate(data []byte) []byte {
+	buf := &bytes.Buffer{}
+	w := zlib.NewWriter(buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+// pngUp encodes data as rows of columns bytes with the PNG Up predictor,
+// padding it with spaces.
+func pngUp(data []byte, columns int) []byte {
+	for len(data)%columns != 0 {
+		data = append(data, ' ')
+	}
+	out := []byte{}
+	prev := make([]byte, columns)
+	for i := 0; i < len(data); i += columns {
+		out = append(out, 2)
+		for j, c := range data[i : i+columns] {
+			out = append(out, c-prev[j])
+		}
+		prev = data[i : i+columns]
+	}
+	return out
+}
+
+// lzw encodes data as LZWDecode codes with early change, tracking the code
+// width like decoders do.
+func lzw(data []byte) []byte {
+	table := map[string]int{}
+	for i := 0; i < 256; i++ {
+		table[string([]byte{byte(i)})] = i
+	}
+	next, size, width := 258, 258, uint(9)
+	out := []byte{}
+	bits, nbits := uint32(0), uint(0)
+	count := 0
+	emit := func(code int) {
+		bits = bits<<width | uint32(code)
+		nbits += width
+		for nbits >= 8 {
+			nbits -= 8
+			out = append(out, byte(bits>>nbits))
+		}
+		// Decoders add an entry for every code but the first one
+		if count > 0 {
+			size++
+		}
+		count++
+		if size+1 >= 1<<width && width < 12 {
+			width++
+		}
+	}
+	w := ""
+	for _, c := range data {
+		wc := w + string([]byte{c})
+		if _, ok := table[wc]; ok {
+			w = wc
+			continue
+		}
+		emit(table[w])
+		table[wc] = next
+		next++
+		w = string([]byte{c})
+	}
+	if w != "" {
+		emit(table[w])
+	}
+	emit(257)
+	if nbits > 0 {
+		out = append(out, byte(bits<<(8-nbits)))
+	}
+	return out
+}
+
+// runLength encodes data as RunLengthDecode literal runs.
+func runLength(data []byte) []byte {
+	out := []byte{}
+	for len(data) > 0 {
+		n := len(data)
+		if n > 128 {
+			n = 128
+		}
+		out = append(out, byte(n-1))
+		out = append(out, data[:n]...)
+		data = data[n:]
+	}
+	return append(out, 128)
+}
+
+// writeFilters writes a statement whose pages content streams are encoded
+// with various filters. The last page only paints an image and its content
+// stream has both Filter and Filters keys.
+func writeFilters() {
+	d := &document{}
+	parent := d.add("")
+	contents := []int{
+		d.addStream("<< /Filter [/FlateDecode /LZWDecode] >>",
+			deflate(lzw([]byte(totalLine(700, "01.03.2020", "1.000,00")+
+				opLine(690, "05.03", "30,00", "", "PRLV SEPA", "ASSURANCE"))))),
+		d.addStream("<< /Filter [/FlateDecode] /DecodeParms [<< /Predictor 12 /Columns 16 >>] >>",
+			deflate(pngUp([]byte(opLine(700, "09.03", "", "1.200,50", "VIR SEPA", "SALAIRE")+
+				totalLine(690, "31.03.2020", "2.170,50")), 16))),
+		d.addStream("<< /Filter /RunLengthDecode /Filters /FlateDecode >>",
+			runLength([]byte("q 100 0 0 100 80 600 cm /Im1 Do Q\n"))),
+	}
+	image := d.addStream("<< /Type /XObject /Subtype /Image /Width 1 /Height 1 "+
+		"/ColorSpace /DeviceGray /BitsPerComponent 8 >>", []byte{0x80})
+	kids := []string{}
+	for _, content := range contents {
+		id := d.add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 595 842] "+
+			"/Resources << /XObject << /Im1 %d 0 R >> >> /Contents %d 0 R >>",
+			parent, image, content))
+		kids = append(kids, fmt.Sprintf("%d 0 R", id))
+	}
+	d.set(parent, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>",
+		strings.Join(kids, " "), len(kids)))
+	catalog := d.add(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", parent))
+	d.write("filters.pdf", catalog, "")
+}
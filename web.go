@@ -3,56 +3,170 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 type WebValue struct {
-	X      int64  `json:"x"`
-	Y      int64  `json:"y"`
-	Source string `json:"n"`
-	Delta  int64  `json:"d"`
+	X        int64  `json:"x"`
+	Y        int64  `json:"y"`
+	Source   string `json:"n"`
+	Delta    int64  `json:"d"`
+	Account  string `json:"a,omitempty"`
+	Category string `json:"c,omitempty"`
+	// Total is true for account records
+	Total bool `json:"t,omitempty"`
 }
 
+// readJsonValues reads values written either as a single JSON array or as
+// newline-delimited JSON objects. Dates are normalized to UTC days.
 func readJsonValues(path string) ([]Value, error) {
+	values, err := decodeJsonValues(path)
+	if err != nil {
+		return nil, err
+	}
+	for i := range values {
+		values[i].Date = utcDay(values[i].Date)
+	}
+	return values, nil
+}
+
+func decodeJsonValues(path string) ([]Value, error) {
 	values := []Value{}
 	fp, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
-	err = json.NewDecoder(fp).Decode(&values)
-	return values, err
+	defer fp.Close()
+	r := bufio.NewReader(fp)
+	for {
+		c, err := r.ReadByte()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			continue
+		}
+		r.UnreadByte()
+		if c == '[' {
+			err = json.NewDecoder(r).Decode(&values)
+			return values, err
+		}
+		break
+	}
+	dec := json.NewDecoder(r)
+	for {
+		v := Value{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
 }
 
-// embedJson replaces the $DATA$ placeholder in html with the javascript
-// representation of input values. It embeds values as json data.
-func embedJson(html []byte, values []Value) ([]byte, error) {
+// toWebValues converts values to their charted representation. Deltas are
+// computed relatively to the previous value of the same account.
+func toWebValues(values []Value) []WebValue {
 	webs := make([]WebValue, 0, len(values))
-	for i, v := range values {
+	last := map[string]int64{}
+	for _, v := range values {
 		delta := int64(0)
-		if i > 0 {
-			delta = v.Value - values[i-1].Value
+		if prev, ok := last[v.Account]; ok {
+			delta = v.Value - prev
 		}
+		last[v.Account] = v.Value
 		webs = append(webs, WebValue{
-			X:      v.Date.Unix(),
-			Y:      v.Value,
-			Source: v.Source,
-			Delta:  delta,
+			X:        v.Date.Unix(),
+			Y:        v.Value,
+			Source:   v.Source,
+			Delta:    delta,
+			Account:  v.Account,
+			Category: v.Category,
+			Total:    v.IsTotal,
 		})
 	}
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(&webs)
-	if err != nil {
-		return nil, err
+	return webs
+}
+
+var (
+	// reDataPlaceholder matches $DATA$ and named $DATA:name$ placeholders.
+	reDataPlaceholder = regexp.MustCompile(`\$DATA(?::(\w+))?\$`)
+)
+
+// dataSeries returns the representation of values substituted to the
+// $DATA:name$ placeholder, $DATA$ standing for $DATA:values$.
+func dataSeries(name string, values []Value) (interface{}, error) {
+	switch name {
+	case "", "values":
+		return toWebValues(values), nil
+	case "balances":
+		return accountBalances(values), nil
+	case "monthly":
+		return monthlyBalances(values), nil
 	}
-	data := bytes.Replace(html, []byte("$DATA$"), buf.Bytes(), 1)
-	return data, nil
+	return nil, fmt.Errorf("unknown data placeholder: %s", name)
+}
+
+// embedJson replaces every $DATA$ or $DATA:name$ placeholder in html with the
+// javascript representation of the corresponding series of input values,
+// see dataSeries. Values are sorted by date first.
+func embedJson(html []byte, values []Value) ([]byte, error) {
+	values = append([]Value{}, values...)
+	sortValues(values)
+	encoded := map[string][]byte{}
+	encode := func(name string) ([]byte, error) {
+		if js, ok := encoded[name]; ok {
+			return js, nil
+		}
+		series, err := dataSeries(name, values)
+		if err != nil {
+			return nil, err
+		}
+		buf := &bytes.Buffer{}
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		err = enc.Encode(series)
+		if err != nil {
+			return nil, err
+		}
+		// Sources are no longer HTML escaped, prevent them from closing the
+		// enclosing script element or opening an HTML comment in it. "<"
+		// only appears in JSON strings where \u003c is equivalent.
+		js := bytes.Replace(buf.Bytes(), []byte("<"), []byte(`\u003c`), -1)
+		encoded[name] = js
+		return js, nil
+	}
+	var err error
+	data := reDataPlaceholder.ReplaceAllFunc(html, func(m []byte) []byte {
+		name := string(reDataPlaceholder.FindSubmatch(m)[1])
+		js, e := encode(name)
+		if e != nil {
+			err = e
+			return m
+		}
+		return js
+	})
+	return data, err
 }
 
 type Matcher func(string) bool
@@ -60,15 +174,20 @@ type Matcher func(string) bool
 // parseIgnoreRules returns a Value.Source matcher from input lines. Empty
 // lines or lines starting with # are ignored. Others are pieced together as
 // alternatives of a single regular expression. Returned matcher succeeds if
-// one of the alternative matches the input string.
+// one of the alternative matches the input string. Each rule is checked on
+// its own first, so errors report the invalid line.
 func parseIgnoreRules(r io.Reader) (Matcher, error) {
 	rules := []string{}
 	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
+	for n := 1; scanner.Scan(); n++ {
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
+		_, err := regexp.Compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid ignore rule %q: %w", n, line, err)
+		}
 		rules = append(rules, line)
 	}
 	if scanner.Err() != nil {
@@ -98,15 +217,270 @@ func readIgnoreFile(path string) (Matcher, error) {
 	return parseIgnoreRules(fp)
 }
 
+// ignoreCache holds the Matcher of an ignore file, reloaded when the file
+// modification time changes.
+type ignoreCache struct {
+	path    string
+	lock    sync.Mutex
+	modTime time.Time
+	matcher Matcher
+}
+
+func newIgnoreCache(path string) *ignoreCache {
+	return &ignoreCache{path: path}
+}
+
+// Matcher returns the ignore file Matcher, reading it again if it changed
+// since last call.
+func (c *ignoreCache) Matcher() (Matcher, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	st, err := os.Stat(c.path)
+	if err != nil {
+		return nil, err
+	}
+	if c.matcher != nil && st.ModTime().Equal(c.modTime) {
+		return c.matcher, nil
+	}
+	m, err := readIgnoreFile(c.path)
+	if err != nil {
+		return nil, err
+	}
+	c.matcher = m
+	c.modTime = st.ModTime()
+	return m, nil
+}
+
+// Renamer rewrites a Value.Source.
+type Renamer func(string) string
+
+// parseRenameRules returns a Value.Source renamer from input lines formatted
+// like "regexp=>replacement". Empty lines or lines starting with # are
+// ignored. The renamer applies the replacement of the first rule matching
+// the input string, and returns it unchanged if no rule matches.
+func parseRenameRules(r io.Reader) (Renamer, error) {
+	type rule struct {
+		Re          *regexp.Regexp
+		Replacement string
+	}
+	rules := []rule{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=>", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: missing => in rename rule: %q", n, line)
+		}
+		re, err := regexp.Compile(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		rules = append(rules, rule{re, strings.TrimSpace(parts[1])})
+	}
+	if scanner.Err() != nil {
+		return nil, scanner.Err()
+	}
+	return func(s string) string {
+		for _, r := range rules {
+			if r.Re.MatchString(s) {
+				return r.Replacement
+			}
+		}
+		return s
+	}, nil
+}
+
+func readRenameFile(path string) (Renamer, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	return parseRenameRules(fp)
+}
+
+// renameCache holds the Renamer of a rename rules file, reloaded when the
+// file modification time changes.
+type renameCache struct {
+	path    string
+	lock    sync.Mutex
+	modTime time.Time
+	renamer Renamer
+}
+
+func newRenameCache(path string) *renameCache {
+	return &renameCache{path: path}
+}
+
+// Renamer returns the rules file Renamer, reading it again if it changed
+// since last call.
+func (c *renameCache) Renamer() (Renamer, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	st, err := os.Stat(c.path)
+	if err != nil {
+		return nil, err
+	}
+	if c.renamer != nil && st.ModTime().Equal(c.modTime) {
+		return c.renamer, nil
+	}
+	r, err := readRenameFile(c.path)
+	if err != nil {
+		return nil, err
+	}
+	c.renamer = r
+	c.modTime = st.ModTime()
+	return r, nil
+}
+
+// parseCategoryRules returns a Value.Source categorizer from input lines
+// formatted like "category: regexp". Empty lines or lines starting with # are
+// ignored. The categorizer returns the category of the first rule partially
+// matching the input string, or an empty string if none matches.
+func parseCategoryRules(r io.Reader) (Renamer, error) {
+	type rule struct {
+		Re       *regexp.Regexp
+		Category string
+	}
+	rules := []rule{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: missing : in category rule: %q", n, line)
+		}
+		re, err := regexp.Compile(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		rules = append(rules, rule{re, strings.TrimSpace(parts[0])})
+	}
+	if scanner.Err() != nil {
+		return nil, scanner.Err()
+	}
+	return func(s string) string {
+		for _, r := range rules {
+			if r.Re.MatchString(s) {
+				return r.Category
+			}
+		}
+		return ""
+	}, nil
+}
+
+func readCategoryFile(path string) (Renamer, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	return parseCategoryRules(fp)
+}
+
+// splitAccounts partitions values by account, preserving their order. It
+// returns the account names sorted alphabetically.
+func splitAccounts(values []Value) ([]string, map[string][]Value) {
+	names := []string{}
+	accounts := map[string][]Value{}
+	for _, v := range values {
+		if _, ok := accounts[v.Account]; !ok {
+			names = append(names, v.Account)
+		}
+		accounts[v.Account] = append(accounts[v.Account], v)
+	}
+	sort.Strings(names)
+	return names, accounts
+}
+
+// AccountBalance is the latest known balance of an account, in minor units.
+type AccountBalance struct {
+	Account string `json:"account"`
+	Date    int64  `json:"date"`
+	Balance int64  `json:"balance"`
+}
+
+// accountBalances returns the last balance of every account in values.
+func accountBalances(values []Value) []AccountBalance {
+	names, accounts := splitAccounts(values)
+	balances := []AccountBalance{}
+	for _, name := range names {
+		vals := accounts[name]
+		last := vals[len(vals)-1]
+		balances = append(balances, AccountBalance{
+			Account: name,
+			Date:    last.Date.Unix(),
+			Balance: last.Value,
+		})
+	}
+	return balances
+}
+
+// MonthBalance is the balance of all accounts at the end of a calendar month,
+// and its change over the month, in minor units.
+type MonthBalance struct {
+	Month   int64 `json:"month"`
+	Balance int64 `json:"balance"`
+	Delta   int64 `json:"delta"`
+}
+
+// monthlyBalances aggregates values by calendar month, from the first to the
+// last month of values. Months without values carry the previous balance
+// forward.
+func monthlyBalances(values []Value) []MonthBalance {
+	months := []MonthBalance{}
+	if len(values) == 0 {
+		return months
+	}
+	monthOf := func(d time.Time) time.Time {
+		return time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	deltas := valueDeltas(values)
+	last := map[string]int64{}
+	balance := int64(0)
+	month := monthOf(values[0].Date)
+	i := 0
+	for i < len(values) {
+		next := month.AddDate(0, 1, 0)
+		mb := MonthBalance{Month: month.Unix()}
+		for ; i < len(values) && monthOf(values[i].Date).Before(next); i++ {
+			v := values[i]
+			balance += v.Value - last[v.Account]
+			last[v.Account] = v.Value
+			mb.Delta += deltas[i]
+		}
+		mb.Balance = balance
+		months = append(months, mb)
+		month = next
+	}
+	return months
+}
+
 // filterValues removes matched values from the input sequence, and adjusts the
-// following values as if the removed operations had never existed.
+// following values as if the removed operations had never existed. Account
+// records are always kept.
 func filterValues(values []Value, m Matcher) []Value {
+	return dropValues(values, func(i int) bool {
+		return !values[i].IsTotal && m(values[i].Source)
+	})
+}
+
+// dropValues removes values at indexes for which drop returns true, and
+// adjusts the following values as if the removed operations had never
+// existed.
+func dropValues(values []Value, drop func(i int) bool) []Value {
 	if len(values) == 0 {
 		return values
 	}
 	kept := []Value{}
 	for i, v := range values {
-		if m(v.Source) {
+		if drop(i) {
 			continue
 		}
 		if len(kept) > 0 {
@@ -119,44 +493,301 @@ func filterValues(values []Value, m Matcher) []Value {
 	return kept
 }
 
+var (
+	reTransfer = regexp.MustCompile(`\bVIR(?:EMENT)?\b`)
+)
+
+// internalTransfers looks for transfers between accounts: a transfer debited
+// from one account and credited to another for the same amount, at most
+// maxDays apart. It returns the indexes of matched values per account.
+func internalTransfers(accounts map[string][]Value, maxDays int) map[string]map[int]bool {
+	type transfer struct {
+		Account string
+		Index   int
+		Delta   int64
+		Date    time.Time
+	}
+	debits := []transfer{}
+	credits := []transfer{}
+	names := []string{}
+	for account := range accounts {
+		names = append(names, account)
+	}
+	sort.Strings(names)
+	for _, account := range names {
+		values := accounts[account]
+		for i := 1; i < len(values); i++ {
+			v := values[i]
+			if !reTransfer.MatchString(v.Source) {
+				continue
+			}
+			t := transfer{
+				Account: account,
+				Index:   i,
+				Delta:   v.Value - values[i-1].Value,
+				Date:    v.Date,
+			}
+			if t.Delta < 0 {
+				debits = append(debits, t)
+			} else if t.Delta > 0 {
+				credits = append(credits, t)
+			}
+		}
+	}
+	maxGap := time.Duration(maxDays) * 24 * time.Hour
+	matched := map[string]map[int]bool{}
+	mark := func(t transfer) {
+		if matched[t.Account] == nil {
+			matched[t.Account] = map[int]bool{}
+		}
+		matched[t.Account][t.Index] = true
+	}
+	used := make([]bool, len(credits))
+	for _, d := range debits {
+		for i, c := range credits {
+			if used[i] || c.Account == d.Account || c.Delta != -d.Delta {
+				continue
+			}
+			gap := c.Date.Sub(d.Date)
+			if gap < -maxGap || gap > maxGap {
+				continue
+			}
+			used[i] = true
+			mark(d)
+			mark(c)
+			break
+		}
+	}
+	return matched
+}
+
+const (
+	// shutdownTimeout bounds the time spent completing pending requests on
+	// shutdown.
+	shutdownTimeout = 10 * time.Second
+)
+
 var (
 	webCmd = app.Command("web", `run charts web frontend
 
-web takes a sequence of JSON values and plots them in HTML at specified address.
+web takes sequences of JSON values and plots them in HTML at specified address.
+Sequences read from several files are merged by date, values listed by
+several files being kept once.
 
 An ignore files can be supplied to remove values from the sequence and make it
 like they never existed. The ignore file lines are regular expression partially
 matching the source of values to remove. Empty line or lines starting with #
 are ignored.
 
+Values can also be searched by passing a "q" query parameter, which keeps
+values whose source contains it, ignoring case. Unlike the ignore file, it does
+not adjust the balances.
+
+A rules file can rename the source of values. Its lines are formatted like
+"regexp=>name" and the first rule whose regular expression partially matches a
+source replaces it with name. Rules are applied after the ignore file.
+
+Values tagged with several accounts can be charted one at a time by passing an
+"account" query parameter. /api/accounts returns the latest balance of each
+account as JSON.
+
+/healthz returns the number of loaded values. The server stops on SIGINT or
+SIGTERM once pending requests are completed.
+
+/api/monthly returns the end of month balance and net change of each month.
+
+/api/values returns the charted values as JSON, with the same filtering and
+query parameters as the HTML page. /download.csv returns them as CSV, with
+--currency-scale decimal digits amounts.
+
+The HTML page template receives the charted values in place of every $DATA$
+placeholder. $DATA:balances$ and $DATA:monthly$ placeholders receive the same
+data as /api/accounts and /api/monthly.
+
 `)
-	webValues = webCmd.Arg("values", "JSON values to display").Required().String()
+	webValues = webCmd.Arg("values", "JSON values to display").Required().Strings()
 	webAddr   = webCmd.Flag("http", "web server address").
 			Default("localhost:8081").String()
-	webIgnorePath = webCmd.Flag("ignore", "path to ignore file").String()
+	webIgnorePath  = webCmd.Flag("ignore", "path to ignore file").String()
+	webNoTransfers = webCmd.Flag("exclude-internal-transfers",
+		"remove transfers between charted accounts").Bool()
+	webRulesPath = webCmd.Flag("rules", "path to source rename rules file").String()
+	webAssets    = webCmd.Flag("assets",
+		"serve main.html and scripts from this directory instead of embedded ones").
+		String()
+	webTemplate = webCmd.Flag("template",
+		"path to HTML page template with a $DATA$ placeholder, instead of main.html").
+		String()
+	webCurrencyScale = webCmd.Flag("currency-scale",
+		"number of decimal digits of amounts").Default("2").Int()
 )
 
 func webFn() error {
-	values, err := readJsonValues(*webValues)
+	seqs := [][]Value{}
+	for _, path := range *webValues {
+		values, err := readJsonValues(path)
+		if err != nil {
+			return err
+		}
+		seqs = append(seqs, values)
+	}
+	values := mergeValues(seqs...)
+	assets, err := openAssets(*webAssets)
 	if err != nil {
 		return err
 	}
-	http.Handle("/scripts/", http.FileServer(http.Dir(".")))
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		kept := values
-		if *webIgnorePath != "" {
-			ignore, err := readIgnoreFile(*webIgnorePath)
+	// Report invalid templates before serving
+	_, err = readTemplate(assets, *webTemplate)
+	if err != nil {
+		return err
+	}
+	// filter applies the ignore rules on each account separately
+	var ignores *ignoreCache
+	if *webIgnorePath != "" {
+		ignores = newIgnoreCache(*webIgnorePath)
+		// Report invalid rules before serving
+		_, err := ignores.Matcher()
+		if err != nil {
+			return err
+		}
+	}
+	var renames *renameCache
+	if *webRulesPath != "" {
+		renames = newRenameCache(*webRulesPath)
+		_, err := renames.Renamer()
+		if err != nil {
+			return err
+		}
+	}
+	filter := func(values []Value) ([]Value, error) {
+		var ignore Matcher
+		if ignores != nil {
+			m, err := ignores.Matcher()
+			if err != nil {
+				return nil, err
+			}
+			ignore = m
+		}
+		var rename Renamer
+		if renames != nil {
+			r, err := renames.Renamer()
 			if err != nil {
-				log.Println(err)
-				return
+				return nil, err
+			}
+			rename = r
+		}
+		names, accounts := splitAccounts(values)
+		transfers := map[string]map[int]bool{}
+		if *webNoTransfers {
+			transfers = internalTransfers(accounts, 3)
+		}
+		kept := []Value{}
+		for _, name := range names {
+			vals := accounts[name]
+			if t := transfers[name]; t != nil {
+				vals = dropValues(vals, func(i int) bool { return t[i] })
 			}
-			kept = filterValues(kept, ignore)
+			if ignore != nil {
+				vals = filterValues(vals, ignore)
+			}
+			for _, v := range vals {
+				if rename != nil {
+					v.Source = rename(v.Source)
+				}
+				kept = append(kept, v)
+			}
+		}
+		sortValues(kept)
+		return kept, nil
+	}
+	// selectValues filters values and keeps the account and sources
+	// requested in r, if any.
+	selectValues := func(r *http.Request) ([]Value, error) {
+		kept, err := filter(values)
+		if err != nil {
+			return nil, err
+		}
+		if account, ok := r.URL.Query()["account"]; ok {
+			_, accounts := splitAccounts(kept)
+			kept = accounts[account[0]]
+		}
+		if q := strings.ToLower(r.URL.Query().Get("q")); q != "" {
+			matched := []Value{}
+			for _, v := range kept {
+				if strings.Contains(strings.ToLower(v.Source), q) {
+					matched = append(matched, v)
+				}
+			}
+			kept = matched
+		}
+		return kept, nil
+	}
+	http.Handle("/scripts/", http.StripPrefix("/scripts/",
+		http.FileServer(http.FS(assets))))
+	http.HandleFunc("/api/accounts", func(w http.ResponseWriter, r *http.Request) {
+		kept, err := filter(values)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(accountBalances(kept))
+		if err != nil {
+			log.Println(err)
+		}
+	})
+	http.HandleFunc("/api/values", func(w http.ResponseWriter, r *http.Request) {
+		kept, err := selectValues(r)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(toWebValues(kept))
+		if err != nil {
+			log.Println(err)
+		}
+	})
+	http.HandleFunc("/api/monthly", func(w http.ResponseWriter, r *http.Request) {
+		kept, err := selectValues(r)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(monthlyBalances(kept))
+		if err != nil {
+			log.Println(err)
+		}
+	})
+	http.HandleFunc("/download.csv", func(w http.ResponseWriter, r *http.Request) {
+		kept, err := selectValues(r)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="values.csv"`)
+		err = csvWriter(".", *webCurrencyScale)(w, kept)
+		if err != nil {
+			log.Println(err)
+		}
+	})
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		kept, err := selectValues(r)
+		if err != nil {
+			log.Println(err)
+			return
 		}
 		if len(kept) == 0 {
 			log.Println("all values were filtered")
 			return
 		}
-		html, err := ioutil.ReadFile("scripts/main.html")
+		html, err := readTemplate(assets, *webTemplate)
 		if err != nil {
 			log.Println(err)
 			return
@@ -169,5 +800,37 @@ func webFn() error {
 		w.Header().Set("Content-Type", "text/html")
 		w.Write(html)
 	})
-	return http.ListenAndServe(*webAddr, nil)
+	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(map[string]int{"values": len(values)})
+		if err != nil {
+			log.Println(err)
+		}
+	})
+	return serve(&http.Server{Addr: *webAddr})
+}
+
+// serve runs srv until it fails or the process receives SIGINT or SIGTERM,
+// in which case pending requests are completed before returning.
+func serve(srv *http.Server) error {
+	stopped := make(chan error, 1)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig, ok := <-signals
+		if !ok {
+			return
+		}
+		log.Printf("received %s, shutting down", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		stopped <- srv.Shutdown(ctx)
+	}()
+	err := srv.ListenAndServe()
+	signal.Stop(signals)
+	if err != http.ErrServerClosed {
+		close(signals)
+		return err
+	}
+	return <-stopped
 }
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmbedJsonTemplate(t *testing.T) {
+	assets, err := openAssets("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	html, err := readTemplate(assets, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := []Value{
+		{Date: time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), Source: "SOLDE",
+			Value: 100000, IsTotal: true},
+		{Date: time.Date(2020, 3, 5, 0, 0, 0, 0, time.UTC), Source: "</script>",
+			Value: 97000, Amount: -3000},
+	}
+	got, err := embedJson(html, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reDataPlaceholder.Match(got) {
+		t.Fatal("placeholders left in embedded template")
+	}
+	want := `var data = [{"x":1583020800,"y":100000,"n":"SOLDE","d":0,"t":true},` +
+		`{"x":1583366400,"y":97000,"n":"\u003c/script>","d":-3000}]`
+	if !bytes.Contains(got, []byte(want)) {
+		i := bytes.Index(got, []byte("var data"))
+		if i < 0 {
+			t.Fatal("data not found in embedded template")
+		}
+		line := strings.SplitN(string(got[i:]), "\n", 2)[0]
+		t.Fatalf("unexpected data:\n%s\n!=\n%s", line, want)
+	}
+}
+
+func TestRenameCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules")
+	write := func(rules string, modTime time.Time) {
+		t.Helper()
+		err := ioutil.WriteFile(path, []byte(rules), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Chtimes(path, modTime, modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	first := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	write("CB.*=>shopping\n", first)
+	c := newRenameCache(path)
+	tests := []struct {
+		rules   string
+		modTime time.Time
+		want    string
+	}{
+		{"", first, "shopping"},
+		// Unchanged modification time, rules are not read again
+		{"CB.*=>cards\n", first, "shopping"},
+		{"CB.*=>cards\n", first.Add(time.Second), "cards"},
+	}
+	for i, test := range tests {
+		if test.rules != "" {
+			write(test.rules, test.modTime)
+		}
+		rename, err := c.Renamer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rename("CB SHOP"); got != test.want {
+			t.Errorf("%d: unexpected rename: %q != %q", i, got, test.want)
+		}
+	}
+}
+
+func TestFilterValues(t *testing.T) {
+	values := []Value{
+		{Date: testDay(1), Source: "SOLDE AU 01.03.2020", Value: 1000, IsTotal: true},
+		{Date: testDay(2), Source: "CB A", Value: 900},
+		{Date: testDay(3), Source: "CB B", Value: 850},
+		{Date: testDay(4), Source: "SOLDE AU 04.03.2020", Value: 850, IsTotal: true},
+		{Date: testDay(5), Source: "CB A", Value: 800},
+		{Date: testDay(6), Source: "CB B", Value: 750},
+	}
+	tests := []struct {
+		rules string
+		want  []string
+	}{
+		{"CB A", []string{
+			"2020-03-01 SOLDE AU 01.03.2020 1000",
+			"2020-03-03 CB B 950",
+			"2020-03-04 SOLDE AU 04.03.2020 950",
+			"2020-03-06 CB B 900",
+		}},
+		// Account records are never removed but rebased like other values
+		{"SOLDE|CB B", []string{
+			"2020-03-01 SOLDE AU 01.03.2020 1000",
+			"2020-03-02 CB A 900",
+			"2020-03-04 SOLDE AU 04.03.2020 900",
+			"2020-03-05 CB A 850",
+		}},
+	}
+	for _, test := range tests {
+		m, err := parseIgnoreRules(strings.NewReader(test.rules))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := valueSummaries(filterValues(values, m))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: unexpected values:\n%s\n!=\n%s", test.rules,
+				strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+	}
+}
+
+func TestDropValues(t *testing.T) {
+	values := []Value{
+		{Date: testDay(1), Source: "SOLDE AU 01.03.2020", Value: 1000, IsTotal: true},
+		{Date: testDay(2), Source: "VIR SEPA EPARGNE", Value: 900},
+		{Date: testDay(3), Source: "CB A", Value: 850},
+		{Date: testDay(4), Source: "SOLDE AU 04.03.2020", Value: 850, IsTotal: true},
+		{Date: testDay(5), Source: "CB B", Value: 800},
+	}
+	// Dropping a transfer, like --no-transfers does, shifts following account
+	// records too, so the series does not jump back at them.
+	got := valueSummaries(dropValues(values, func(i int) bool { return i == 1 }))
+	want := []string{
+		"2020-03-01 SOLDE AU 01.03.2020 1000",
+		"2020-03-03 CB A 950",
+		"2020-03-04 SOLDE AU 04.03.2020 950",
+		"2020-03-05 CB B 900",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected values:\n%s\n!=\n%s", strings.Join(got, "\n"),
+			strings.Join(want, "\n"))
+	}
+}